@@ -1,187 +1,453 @@
 package cmd
 
 import (
-	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"net"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
-// backupCmd represents the command to back up a PostgreSQL database
+// backupCmd represents the command to back up a database
 var backupCmd = &cobra.Command{
 	Use: "backup <db_config> <local_save_path>",
-	Short: `Backup a PostgreSQL database, locally or over SSH.
+	Short: `Backup a PostgreSQL, MySQL/MariaDB, SQLite or MongoDB database, locally or over SSH.
 	
-		db_config: <username>:<password>@<host>:<port>/<dbname>
+		db_config: <username>[:<password>]@<host>:<port>/<dbname>
+		    or postgres://<username>:<password>@<host>:<port>/<dbname>?sslmode=require
+		    or mysql://<username>:<password>@<host>:<port>/<dbname>
+		    or sqlite:///<path>
+		    or mongodb://<username>:<password>@<host>:<port>/<dbname>
+		    or "host=<host> port=<port> user=<username> password=<password> dbname=<dbname>"
+		Leave the password out to read it from --password-file, --password-env,
+		--password-secret, --password-prompt or, for PostgreSQL, ~/.pgpass.
+		db_config may also be a secret reference: env://<NAME>, file://<path>,
+		secret://vault/<mount>/<path>#<key> or secret://pass/<path>[#<key>].
 		e.g., postgres:v8hlDV0yMAHHlIurYupj@10.1.0.54:15432/golang
 
-		--remote: <user>@<host>:<remote-db-port>
-		e.g., --remote admin@192.168.1.10:5432`,
-	Args: cobra.ExactArgs(2),
+		--remote: [<user>@]<host>[[:<ssh-port>]:<remote-db-port>]
+		e.g., --remote admin@192.168.1.10:5432 or --remote bastion:2222:5432
+		The host may be an alias from ~/.ssh/config
+
+		--remote-exec runs pg_dump on the --remote host and streams the dump
+		back over SSH, for hosts that do not allow port forwarding
+
+		--encrypt-to <age-recipient> or --passphrase-file <file> encrypt
+		the backup before it is written to disk
+
+		--upload s3://<bucket>/<prefix> copies the backup and its manifest
+		to S3-compatible storage configured in ~/.omti.yaml or AWS_* variables
+
+		--format custom|plain|directory|tar selects the pg_dump format and
+		file extension (.dump, .sql, .dir, .tar); --jobs dumps a directory in parallel
+
+		--schema/--exclude-schema/--table/--exclude-table/--exclude-table-data
+		restrict the dump to some objects, and --schema-only/--data-only to
+		definitions or rows; all are recorded in the manifest
+
+		--keep-last/--keep-daily/--keep-weekly/--keep-monthly prune older
+		backups of the same database after a successful backup
+
+		--inventory <file> backs up every database listed in a YAML file,
+		--parallel of them at a time, instead of a single db_config
+
+		--progress auto|live|log|json|none reports the bytes dumped, the
+		throughput, the current table and a percentage estimated from the
+		database size on stderr; json writes one event per line for CI logs`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if inventoryFlag == "" {
+			return cobra.ExactArgs(2)(cmd, args)
+		}
+		if len(args) > 0 {
+			return fmt.Errorf("--inventory does not take a db_config or local_save_path")
+		}
+		return nil
+	},
 	Run: func(cmd *cobra.Command, args []string) {
+		if inventoryFlag != "" {
+			runInventoryBackup(cmd)
+			return
+		}
+
 		dbConfig := args[0]
 		localSavePath := args[1]
 
+		ctx := cmd.Context()
 		logger := createCustomLogger()
 		logger.Info("🚀 Starting database backup process")
 
-		var err error
-		var dbUser, dbPassword, dbHost, dbPort, dbName string
+		if err := checkProgressMode(progressFlag); err != nil {
+			logger.Fatalf("❌ Invalid --progress: %v", err)
+		}
 
-		if remoteFlag != "" {
-			dbUser, dbPassword, dbHost, dbPort, dbName, err = parseDBConfig(dbConfig)
-			if err != nil {
-				logger.Fatalf("❌ Invalid database configuration format: %v", err)
-			}
-			remoteUser, remoteHost, remoteDBPort, err := parseRemoteFlag(remoteFlag)
-			if err != nil {
-				logger.Fatalf("❌ Invalid --remote format: %v", err)
-			}
-			err = backupDatabaseRemote(dbUser, dbPassword, dbHost, "5433", dbName, localSavePath, remoteUser, remoteHost, remoteDBPort)
-		} else {
-			dbUser, dbPassword, dbHost, dbPort, dbName, err = parseDBConfig(dbConfig)
-			if err != nil {
-				logger.Fatalf("❌ Invalid database configuration format: %v", err)
-			}
-			err = backupDatabaseLocal(dbUser, dbPassword, dbHost, dbPort, dbName, localSavePath)
+		if err := passwordFlags.readPrompt(dbConfig); err != nil {
+			logger.Fatalf("❌ Invalid password settings: %v", err)
+		}
+
+		plan, err := backupJobFromFlags(dbConfig, localSavePath).prepare(ctx)
+		if err != nil {
+			logger.Fatalf("❌ Invalid backup settings: %v", err)
 		}
 
+		backupFile, err := plan.backup(ctx)
 		if err != nil {
 			logger.Fatalf("❌ Database backup failed: %v", err)
 		}
 
 		logger.Info("✅ Database backup completed successfully")
+
+		if plan.job.Upload != "" {
+			if err := plan.upload(ctx, backupFile); err != nil {
+				logger.Fatalf("❌ Backup upload failed: %v", err)
+			}
+			logger.Info("✅ Backup uploaded successfully")
+		}
+
+		if plan.retention.enabled() {
+			if err := plan.prune(); err != nil {
+				logger.Fatalf("❌ Pruning old backups failed: %v", err)
+			}
+			logger.Info("✅ Old backups pruned according to the retention policy")
+		}
 	},
 }
 
 var (
-	remoteFlag string
+	remoteFlag         string
+	localPortFlag      string
+	remoteExecFlag     bool
+	compressFlag       string
+	compressLevelFlag  int
+	encryptToFlag      []string
+	passphraseFileFlag string
+	uploadFlag         string
+	dumpFormatFlag     string
+	dumpJobsFlag       int
+
+	inventoryFlag string
+	parallelFlag  int
 )
 
 func init() {
 	dbCmd.AddCommand(backupCmd)
-	backupCmd.Flags().StringVar(&remoteFlag, "remote", "", "Specify remote connection in format <user>@<host>:<db_port>")
+	addBackupFlags(backupCmd)
+	backupCmd.Flags().StringVar(&inventoryFlag, "inventory", "", "Back up every database listed in this YAML inventory file")
+	backupCmd.Flags().IntVar(&parallelFlag, "parallel", 1, "Number of inventory backups to run at the same time")
+	backupCmd.Flags().StringVar(&progressFlag, "progress", "auto", "Progress reporting on stderr: auto, live, log, json or none")
 }
 
-// parseDBConfig parses the local database configuration in the format <username>:<password>@<host>:<port>/<dbname>
-func parseDBConfig(config string) (user, password, host, port, dbName string, err error) {
-	// Expected format: <username>:<password>@<host>:<port>/<dbname>
-	parts := strings.Split(config, "@")
-	if len(parts) != 2 {
-		return "", "", "", "", "", fmt.Errorf("missing or invalid user and host format")
-	}
+// addBackupFlags registers the flags that describe how a single backup is taken
+func addBackupFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&remoteFlag, "remote", "", "Specify remote connection in format [<user>@]<host>[[:<ssh_port>]:<db_port>] (default port: the one in db_config)")
+	cmd.Flags().StringVar(&localPortFlag, "local-port", "", "Local port for the SSH tunnel (default: a free ephemeral port)")
+	cmd.Flags().BoolVar(&remoteExecFlag, "remote-exec", false, "Run pg_dump on the --remote host over SSH and stream the dump back instead of tunnelling the database port")
+	cmd.Flags().StringVar(&compressFlag, "compress", "none", "Compress the backup with gzip, zstd, lz4 or none (none keeps pg_dump's built-in compression)")
+	cmd.Flags().IntVar(&compressLevelFlag, "level", 0, "Compression level for --compress (default: the codec's default level)")
+	cmd.Flags().StringArrayVar(&encryptToFlag, "encrypt-to", nil, "Encrypt the backup to an age recipient public key (age1...), can be repeated")
+	cmd.Flags().StringVar(&passphraseFileFlag, "passphrase-file", "", "Encrypt the backup with the passphrase stored in this file")
+	cmd.Flags().StringVar(&uploadFlag, "upload", "", "Upload the backup and its manifest to S3-compatible storage, e.g. s3://bucket/prefix")
+	cmd.Flags().StringVar(&dumpFormatFlag, "format", "", "pg_dump output format: custom, plain, directory or tar (default custom, PostgreSQL only)")
+	cmd.Flags().IntVarP(&dumpJobsFlag, "jobs", "j", 1, "Number of parallel pg_dump jobs, requires --format directory")
+	addPasswordFlags(cmd, &passwordFlags)
+	addDumpFilterFlags(cmd, &dumpFiltersFlag)
+	addRetentionFlags(cmd, &retention)
+}
 
-	userPass := strings.Split(parts[0], ":")
-	if len(userPass) != 2 {
-		return "", "", "", "", "", fmt.Errorf("missing or invalid username and password format")
-	}
+// runInventoryBackup backs up every database in the --inventory file and exits
+// with an error if any of them failed
+func runInventoryBackup(cmd *cobra.Command) {
+	logger := createCustomLogger()
+	logger.Info("🚀 Starting inventory backup process")
 
-	user, password = userPass[0], userPass[1]
-	hostPortDB := strings.Split(parts[1], ":")
-	if len(hostPortDB) != 2 {
-		return "", "", "", "", "", fmt.Errorf("missing or invalid host and port format")
+	var conflicting []string
+	cmd.LocalNonPersistentFlags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Changed && flag.Name != "inventory" && flag.Name != "parallel" && flag.Name != "progress" {
+			conflicting = append(conflicting, "--"+flag.Name)
+		}
+	})
+	if len(conflicting) > 0 {
+		logger.Fatalf("❌ %s cannot be combined with --inventory, set them per backup in the inventory file", strings.Join(conflicting, ", "))
+	}
+	if parallelFlag < 1 {
+		logger.Fatalf("❌ Invalid --parallel value %d: must be at least 1", parallelFlag)
+	}
+	if err := checkProgressMode(progressFlag); err != nil {
+		logger.Fatalf("❌ Invalid --progress: %v", err)
+	}
+	// Parallel backups would overwrite each other's live status line
+	if parallelFlag > 1 && resolveProgressMode(progressFlag) == "live" {
+		progressFlag = "log"
 	}
 
-	host, portDB := hostPortDB[0], hostPortDB[1]
-	portDBSplit := strings.Split(portDB, "/")
-	if len(portDBSplit) != 2 {
-		return "", "", "", "", "", fmt.Errorf("missing or invalid database name format")
+	jobs, err := loadInventory(cmd.Context(), inventoryFlag)
+	if err != nil {
+		logger.Fatalf("❌ Invalid inventory: %v", err)
 	}
 
-	port, dbName = portDBSplit[0], portDBSplit[1]
-	return user, password, host, port, dbName, nil
+	results := runInventory(cmd.Context(), jobs, parallelFlag, logger)
+	fmt.Println()
+	printInventorySummary(results)
+
+	failed := 0
+	for _, result := range results {
+		if result.err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		logger.Fatalf("❌ %d of %d backups failed", failed, len(results))
+	}
+	logger.Infof("✅ All %d backups completed successfully", len(results))
 }
 
-// parseRemoteFlag parses the remote flag string in the format <user>@<host>:<db_port>
+// parseRemoteFlag parses the remote flag string in the format
+// [<user>@]<host>[[:<ssh_port>]:<db_port>], with IPv6 hosts in brackets. A lone
+// port is the database port, as in user@host:5432. The SSH port, when given, is
+// returned with the host as host:port. user and dbPort are empty when left out.
 func parseRemoteFlag(remote string) (user, host, dbPort string, err error) {
-	parts := strings.Split(remote, "@")
-	if len(parts) != 2 {
+	user, hostPorts, hasUser := strings.Cut(remote, "@")
+	if !hasUser {
+		user, hostPorts = "", remote
+	}
+	if (hasUser && user == "") || strings.Contains(hostPorts, "@") {
 		return "", "", "", fmt.Errorf("missing or invalid remote user and host format")
 	}
 
-	user = parts[0]
-	hostPort := strings.Split(parts[1], ":")
-	if len(hostPort) != 2 {
+	host, ports := hostPorts, ""
+	if strings.HasPrefix(hostPorts, "[") {
+		end := strings.Index(hostPorts, "]")
+		if end < 0 || (end+1 < len(hostPorts) && hostPorts[end+1] != ':') {
+			return "", "", "", fmt.Errorf("missing or invalid host and port format")
+		}
+		host, ports = hostPorts[1:end], strings.TrimPrefix(hostPorts[end+1:], ":")
+	} else {
+		host, ports, _ = strings.Cut(hostPorts, ":")
+	}
+	portParts := strings.Split(ports, ":")
+	if host == "" || len(portParts) > 2 || (len(portParts) == 2 && portParts[0] == "") {
 		return "", "", "", fmt.Errorf("missing or invalid host and port format")
 	}
 
-	host = hostPort[0]
-	dbPort = hostPort[1]
+	dbPort = portParts[len(portParts)-1]
+	if len(portParts) == 2 {
+		host = net.JoinHostPort(host, portParts[0])
+	}
 	return user, host, dbPort, nil
 }
 
 // backupDatabaseLocal performs the database backup locally without SSH tunnel
-func backupDatabaseLocal(dbUser, dbPassword, dbHost, dbPort, dbName, localSavePath string) error {
-	timestamp := time.Now().Format("20060102_150405")
-	backupFile := filepath.Join(localSavePath, fmt.Sprintf("%s_backup_%s.sql", dbName, timestamp))
+func backupDatabaseLocal(ctx context.Context, conn *dbConnection, localSavePath string, pipeline *backupPipeline, opts dumpOptions) (string, error) {
+	opts.progress = dumpProgress(ctx, "backup", conn, opts)
+	return dumpDatabase(ctx, conn, localSavePath, pipeline, opts, &backupManifest{
+		Database: conn.dbName,
+		Host:     conn.remoteHost(),
+		Port:     conn.port,
+	}, conn.engine.dump)
+}
 
-	pgDumpCmd := exec.Command("pg_dump",
-		"-h", dbHost,
-		"-p", dbPort,
-		"-U", dbUser,
-		"-d", dbName,
-		"-F", "c",
-		"-f", backupFile,
-	)
+// backupDatabaseRemote performs the database backup over an SSH tunnel, or over an
+// SSH session for file-based engines, and saves it locally
+func backupDatabaseRemote(ctx context.Context, conn *dbConnection, localPort, localSavePath, remoteUser, remoteHost, remoteDBPort string, pipeline *backupPipeline, opts dumpOptions) (string, error) {
+	manifest := &backupManifest{
+		Database: conn.dbName,
+		Host:     conn.remoteHost(),
+		Port:     remoteDBPort,
+		Remote:   sshTarget(remoteUser, remoteHost),
+	}
 
-	pgDumpCmd.Env = append(os.Environ(), fmt.Sprintf("PGPASSWORD=%s", dbPassword))
-	var stdOut, stdErr bytes.Buffer
-	pgDumpCmd.Stdout = &stdOut
-	pgDumpCmd.Stderr = &stdErr
+	if engine, ok := conn.engine.(sshEngine); ok {
+		opts.progress = newProgressReporter("backup", conn.dbName, 0)
+		return dumpDatabase(ctx, conn, localSavePath, pipeline, opts, manifest, func(ctx context.Context, conn *dbConnection, opts dumpOptions) (io.ReadCloser, error) {
+			return engine.dumpOverSSH(ctx, conn, remoteUser, remoteHost)
+		})
+	}
 
-	if err := pgDumpCmd.Run(); err != nil {
-		return fmt.Errorf("failed to execute pg_dump: %w\nOutput: %s\nError: %s", err, stdOut.String(), stdErr.String())
+	tunnel, err := startSSHTunnel(ctx, localPort, conn.remoteHost(), remoteUser, remoteHost, remoteDBPort)
+	if err != nil {
+		return "", err
 	}
+	defer tunnel.Close()
 
-	fmt.Printf("✅ Backup saved to %s\n", backupFile)
-	return nil
+	conn = conn.withAddress("127.0.0.1", tunnel.localPort())
+	opts.progress = dumpProgress(ctx, "backup", conn, opts)
+	return dumpDatabase(ctx, conn, localSavePath, pipeline, opts, manifest, conn.engine.dump)
 }
 
-// backupDatabaseRemote performs the database backup over an SSH tunnel and saves it locally
-func backupDatabaseRemote(dbUser, dbPassword, dbHost, dbPort, dbName, localSavePath, remoteUser, remoteHost, remoteDBPort string) error {
-	timestamp := time.Now().Format("20060102_150405")
-	backupFile := filepath.Join(localSavePath, fmt.Sprintf("%s_backup_%s.sql", dbName, timestamp))
-
-	// Start SSH tunnel to forward to specified local dbPort
-	tunnelCmd := exec.Command("ssh", "-fN", "-L", fmt.Sprintf("%s:%s:%s", dbPort, dbHost, remoteDBPort), fmt.Sprintf("%s@%s", remoteUser, remoteHost))
-	tunnelCmd.Stdout = os.Stdout
-	tunnelCmd.Stderr = os.Stderr
+// backupDatabaseRemoteExec runs the dump tool on the remote host over an SSH
+// session and streams its output into the local backup pipeline, for hosts that
+// do not allow port forwarding
+func backupDatabaseRemoteExec(ctx context.Context, conn *dbConnection, localSavePath, remoteUser, remoteHost, remoteDBPort string, pipeline *backupPipeline, opts dumpOptions) (string, error) {
+	engine, ok := conn.engine.(remoteExecEngine)
+	if !ok {
+		return "", fmt.Errorf("--remote-exec is not supported for %s backups", conn.engine.name())
+	}
 
-	if err := tunnelCmd.Run(); err != nil {
-		return fmt.Errorf("failed to start SSH tunnel: %w", err)
+	manifest := &backupManifest{
+		Database: conn.dbName,
+		Host:     conn.remoteHost(),
+		Port:     remoteDBPort,
+		Remote:   sshTarget(remoteUser, remoteHost),
 	}
+	fmt.Printf("🔗 Running the %s dump on %s\n", conn.engine.name(), sshTarget(remoteUser, remoteHost))
+	opts.progress = newProgressReporter("backup", conn.dbName, 0)
+	return dumpDatabase(ctx, conn.withAddress(conn.host, remoteDBPort), localSavePath, pipeline, opts, manifest, func(ctx context.Context, conn *dbConnection, opts dumpOptions) (io.ReadCloser, error) {
+		return engine.dumpOnRemote(ctx, conn, remoteUser, remoteHost, opts)
+	})
+}
 
-	defer func() {
-		if err := killProcessOnPort(dbPort); err != nil {
-			fmt.Printf("❌ Failed to kill SSH tunnel process on port %s: %v\n", dbPort, err)
-		} else {
-			fmt.Println("✅ SSH tunnel process on port", dbPort, "terminated successfully.")
+// dumpProgress starts reporting the progress of an operation dumping conn as
+// opts describe, with the expected dump size as the estimate where the engine
+// can tell it
+func dumpProgress(ctx context.Context, operation string, conn *dbConnection, opts dumpOptions) *progressReporter {
+	var total int64
+	if estimator, ok := conn.engine.(sizeEstimator); ok && resolveProgressMode(progressFlag) != "none" {
+		size, err := estimator.dumpSize(ctx, conn, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️ Cannot estimate the %s progress of %s: %v\n", operation, conn.dbName, err)
 		}
-	}()
+		total = size
+	}
+	return newProgressReporter(operation, conn.dbName, total)
+}
 
-	pgDumpCmd := exec.Command("pg_dump",
-		"-h", "localhost",
-		"-p", dbPort,
-		"-U", dbUser,
-		"-d", dbName,
-		"-F", "c",
-		"-f", backupFile,
-	)
+// dumpDatabase starts a dump of conn with startDump, streams it through the
+// backup pipeline into localSavePath and writes the completed manifest next to
+// it, returning the backup file path. Directory dumps are written by the
+// engine's dump tool directly. The progress reporter in opts is finished once
+// the dump is written. A dump interrupted through ctx leaves no files behind.
+func dumpDatabase(ctx context.Context, conn *dbConnection, localSavePath string, pipeline *backupPipeline, opts dumpOptions, manifest *backupManifest, startDump func(ctx context.Context, conn *dbConnection, opts dumpOptions) (io.ReadCloser, error)) (string, error) {
+	startedAt := time.Now()
+	if opts.name == "" {
+		opts.name = conn.dbName
+	}
+	backupFile := filepath.Join(localSavePath, fmt.Sprintf("%s_backup_%s%s%s", opts.name, startedAt.Format("20060102_150405"), conn.engine.dumpExt(opts.format), pipeline.ext()))
+	opts.compressed = pipeline.codec.name != "none"
+
+	var result *pipelineResult
+	var err error
+	if opts.format == "directory" {
+		result, err = dumpToDirectory(ctx, conn, backupFile, opts)
+	} else {
+		result, err = streamDump(ctx, conn, backupFile, pipeline, opts, startDump)
+	}
+	opts.progress.finish(err)
+	if err != nil {
+		return "", err
+	}
+	finishedAt := time.Now()
+
+	manifest.File = filepath.Base(backupFile)
+	manifest.Name = opts.name
+	manifest.Engine = conn.engine.name()
+	manifest.Compression = pipeline.codec.name
+	manifest.Encryption = pipeline.encryption()
+	if !opts.filters.empty() {
+		manifest.Filters = &opts.filters
+	}
+	conn.engine.describeDump(ctx, result.header, manifest)
+	if opts.format != "" {
+		manifest.Format = opts.format
+	}
+	manifest.StartedAt = startedAt
+	manifest.FinishedAt = finishedAt
+	manifest.DurationSeconds = finishedAt.Sub(startedAt).Seconds()
+	manifest.SizeBytes = result.size
+	manifest.SHA256 = result.checksum
+	if err := writeManifest(backupFile, manifest); err != nil {
+		return "", err
+	}
 
-	pgDumpCmd.Env = append(os.Environ(), fmt.Sprintf("PGPASSWORD=%s", dbPassword))
-	var stdOut, stdErr bytes.Buffer
-	pgDumpCmd.Stdout = &stdOut
-	pgDumpCmd.Stderr = &stdErr
+	fmt.Printf("✅ Backup saved to %s\n", backupFile)
+	fmt.Printf("✅ Manifest saved to %s\n", manifestPath(backupFile))
+	return backupFile, nil
+}
 
-	if err := pgDumpCmd.Run(); err != nil {
-		return fmt.Errorf("failed to execute pg_dump: %w\nOutput: %s\nError: %s", err, stdOut.String(), stdErr.String())
+// streamDump writes the output of startDump through the backup pipeline into a
+// temporary file next to backupFile, which is renamed to backupFile once the
+// dump succeeded and the synced file was read back intact
+func streamDump(ctx context.Context, conn *dbConnection, backupFile string, pipeline *backupPipeline, opts dumpOptions, startDump func(ctx context.Context, conn *dbConnection, opts dumpOptions) (io.ReadCloser, error)) (*pipelineResult, error) {
+	file, err := createPartialFile(backupFile)
+	if err != nil {
+		return nil, err
+	}
+	partial := file.Name()
+
+	dumpCtx, stopDump := context.WithCancel(ctx)
+	defer stopDump()
+	dump, err := startDump(dumpCtx, conn, opts)
+	if err != nil {
+		file.Close()
+		os.Remove(partial)
+		return nil, err
 	}
 
-	fmt.Printf("✅ Backup saved to %s\n", backupFile)
-	return nil
+	result, writeErr := pipeline.write(io.TeeReader(dump, opts.progress), file)
+	if writeErr != nil {
+		// Stop the dump instead of reading it to its end
+		stopDump()
+		dumpErr := dump.Close()
+		os.Remove(partial)
+		if interruption(ctx) != nil {
+			return nil, dumpErr
+		}
+		return nil, writeErr
+	}
+	if err := dump.Close(); err != nil {
+		os.Remove(partial)
+		return nil, err
+	}
+	if err := commitPartialFile(ctx, partial, backupFile, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// dumpToDirectory has the engine dump the database into a temporary directory
+// next to backupFile, returning its total size and tree checksum. The directory
+// is synced and checked by the engine before it is renamed to backupFile.
+func dumpToDirectory(ctx context.Context, conn *dbConnection, backupFile string, opts dumpOptions) (*pipelineResult, error) {
+	engine, ok := conn.engine.(directoryEngine)
+	if !ok {
+		return nil, fmt.Errorf("%s does not support directory dumps", conn.engine.name())
+	}
+
+	partial, err := createPartialDir(backupFile)
+	if err != nil {
+		return nil, err
+	}
+	result, err := writeDirectoryDump(ctx, conn, engine, partial, opts)
+	if err == nil {
+		err = commitPartial(partial, backupFile)
+	}
+	if err != nil {
+		os.RemoveAll(partial)
+		return nil, err
+	}
+	return result, nil
+}
+
+// writeDirectoryDump dumps the database into dir and checks the synced dump
+func writeDirectoryDump(ctx context.Context, conn *dbConnection, engine directoryEngine, dir string, opts dumpOptions) (*pipelineResult, error) {
+	opts.progress.watchDir(dir)
+	header, err := engine.dumpToDirectory(ctx, conn, dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := syncTree(dir); err != nil {
+		return nil, err
+	}
+	if _, err := engine.verifyDirectory(ctx, dir); err != nil {
+		return nil, fmt.Errorf("directory dump failed verification: %w", err)
+	}
+	size, checksum, err := dirChecksum(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &pipelineResult{size: size, checksum: checksum, header: header}, nil
 }
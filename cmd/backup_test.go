@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRemoteFlag(t *testing.T) {
+	tests := []struct {
+		remote   string
+		wantUser string
+		wantHost string
+		wantPort string
+		wantErr  string
+	}{
+		{remote: "ubuntu@db.example.com", wantUser: "ubuntu", wantHost: "db.example.com"},
+		{remote: "db.example.com", wantHost: "db.example.com"},
+		{remote: "ubuntu@db.example.com:5432", wantUser: "ubuntu", wantHost: "db.example.com", wantPort: "5432"},
+		{remote: "ubuntu@db.example.com:2222:5432", wantUser: "ubuntu", wantHost: "db.example.com:2222", wantPort: "5432"},
+		{remote: "db.example.com:2222:5432", wantHost: "db.example.com:2222", wantPort: "5432"},
+		{remote: "ubuntu@[::1]", wantUser: "ubuntu", wantHost: "::1"},
+		{remote: "ubuntu@[::1]:5432", wantUser: "ubuntu", wantHost: "::1", wantPort: "5432"},
+		{remote: "[2001:db8::1]:2222:5432", wantHost: "[2001:db8::1]:2222", wantPort: "5432"},
+		{remote: "@db.example.com", wantErr: "missing or invalid remote user and host format"},
+		{remote: "ubuntu@admin@db.example.com", wantErr: "missing or invalid remote user and host format"},
+		{remote: "ubuntu@", wantErr: "missing or invalid host and port format"},
+		{remote: "ubuntu@:5432", wantErr: "missing or invalid host and port format"},
+		{remote: "ubuntu@db.example.com::5432", wantErr: "missing or invalid host and port format"},
+		{remote: "ubuntu@db.example.com:1:2:3", wantErr: "missing or invalid host and port format"},
+		{remote: "ubuntu@[::1", wantErr: "missing or invalid host and port format"},
+		{remote: "ubuntu@[::1]5432", wantErr: "missing or invalid host and port format"},
+		{remote: "ubuntu@::1", wantErr: "missing or invalid host and port format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.remote, func(t *testing.T) {
+			user, host, dbPort, err := parseRemoteFlag(tt.remote)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseRemoteFlag(%q) error = %v, want it to contain %q", tt.remote, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRemoteFlag(%q) failed: %v", tt.remote, err)
+			}
+			if user != tt.wantUser || host != tt.wantHost || dbPort != tt.wantPort {
+				t.Errorf("parseRemoteFlag(%q) = %q, %q, %q, want %q, %q, %q", tt.remote, user, host, dbPort, tt.wantUser, tt.wantHost, tt.wantPort)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+)
+
+// backupJob holds everything needed to take one backup. It is filled from the
+// backup command's flags or from a job in the config file.
+type backupJob struct {
+	Name           string         `yaml:"name"`
+	DB             string         `yaml:"db"`
+	Password       passwordSource `yaml:",inline"`
+	Path           string         `yaml:"path"`
+	Remote         string         `yaml:"remote"`
+	LocalPort      string         `yaml:"local_port"`
+	RemoteExec     bool           `yaml:"remote_exec"`
+	Compress       string         `yaml:"compress"`
+	Level          int            `yaml:"level"`
+	EncryptTo      []string       `yaml:"encrypt_to"`
+	PassphraseFile string         `yaml:"passphrase_file"`
+	Upload         string         `yaml:"upload"`
+	Format         string         `yaml:"format"`
+	Jobs           int            `yaml:"jobs"`
+	Filters        dumpFilters    `yaml:",inline"`
+	KeepLast       int            `yaml:"keep_last"`
+	KeepDaily      int            `yaml:"keep_daily"`
+	KeepWeekly     int            `yaml:"keep_weekly"`
+	KeepMonthly    int            `yaml:"keep_monthly"`
+}
+
+// backupPlan is a validated backupJob ready to run
+type backupPlan struct {
+	job       *backupJob
+	conn      *dbConnection
+	pipeline  *backupPipeline
+	dump      dumpOptions
+	retention retentionPolicy
+
+	remoteUser   string
+	remoteHost   string
+	remoteDBPort string
+}
+
+// backupJobFromFlags builds a job from the backup command's arguments and flags
+func backupJobFromFlags(dbConfig, localSavePath string) *backupJob {
+	return &backupJob{
+		DB:             dbConfig,
+		Password:       passwordFlags,
+		Path:           localSavePath,
+		Remote:         remoteFlag,
+		LocalPort:      localPortFlag,
+		RemoteExec:     remoteExecFlag,
+		Compress:       compressFlag,
+		Level:          compressLevelFlag,
+		EncryptTo:      encryptToFlag,
+		PassphraseFile: passphraseFileFlag,
+		Upload:         uploadFlag,
+		Format:         dumpFormatFlag,
+		Jobs:           dumpJobsFlag,
+		Filters:        dumpFiltersFlag,
+		KeepLast:       retention.keepLast,
+		KeepDaily:      retention.keepDaily,
+		KeepWeekly:     retention.keepWeekly,
+		KeepMonthly:    retention.keepMonthly,
+	}
+}
+
+// prepare validates the job's settings before any work is done
+func (j *backupJob) prepare(ctx context.Context) (*backupPlan, error) {
+	if j.DB == "" || j.Path == "" {
+		return nil, fmt.Errorf("a database configuration and a local save path are required")
+	}
+
+	plan := &backupPlan{
+		job: j,
+		retention: retentionPolicy{
+			keepLast:    j.KeepLast,
+			keepDaily:   j.KeepDaily,
+			keepWeekly:  j.KeepWeekly,
+			keepMonthly: j.KeepMonthly,
+		},
+	}
+	if err := plan.retention.validate(); err != nil {
+		return nil, fmt.Errorf("retention policy: %w", err)
+	}
+
+	compress := j.Compress
+	if compress == "" {
+		compress = "none"
+	}
+	var err error
+	plan.pipeline, err = newBackupPipeline(compress, j.Level, j.EncryptTo, j.PassphraseFile)
+	if err != nil {
+		return nil, fmt.Errorf("backup output: %w", err)
+	}
+
+	plan.conn, err = resolveDBConfig(ctx, j.DB)
+	if err != nil {
+		return nil, fmt.Errorf("database configuration: %w", err)
+	}
+	if err := j.Password.apply(ctx, plan.conn); err != nil {
+		return nil, fmt.Errorf("database password: %w", err)
+	}
+
+	if err := j.Filters.validate(); err != nil {
+		return nil, fmt.Errorf("object filters: %w", err)
+	}
+	if j.Jobs < 0 {
+		return nil, fmt.Errorf("invalid jobs value %d: must be at least 1", j.Jobs)
+	}
+	plan.dump = dumpOptions{
+		format:     j.Format,
+		jobs:       j.Jobs,
+		filters:    j.Filters,
+		compressed: compress != "none",
+		name:       j.backupName(plan.conn),
+	}
+	if err := plan.conn.engine.checkDump(plan.dump); err != nil {
+		return nil, err
+	}
+	// Directory dumps are written by the dump tool itself, file by file
+	if j.Format == "directory" && (compress != "none" || plan.pipeline.encryption() != "none" || j.Upload != "") {
+		return nil, fmt.Errorf("the directory format cannot be combined with --compress, encryption or --upload")
+	}
+
+	if j.Upload != "" {
+		if _, _, err := parseS3URL(j.Upload); err != nil {
+			return nil, fmt.Errorf("upload destination: %w", err)
+		}
+	}
+
+	if j.Remote != "" {
+		plan.remoteUser, plan.remoteHost, plan.remoteDBPort, err = parseRemoteFlag(j.Remote)
+		if err != nil {
+			return nil, fmt.Errorf("remote: %w", err)
+		}
+		if plan.remoteDBPort == "" {
+			plan.remoteDBPort = plan.conn.port
+		}
+	}
+
+	if j.RemoteExec {
+		switch {
+		case j.Remote == "":
+			return nil, fmt.Errorf("--remote-exec requires --remote")
+		case j.LocalPort != "":
+			return nil, fmt.Errorf("--remote-exec does not open a tunnel, --local-port cannot be used with it")
+		case j.Format == "directory":
+			return nil, fmt.Errorf("the directory format cannot be streamed, it cannot be used with --remote-exec")
+		}
+		if _, ok := plan.conn.engine.(remoteExecEngine); !ok {
+			return nil, fmt.Errorf("--remote-exec is not supported for %s backups", plan.conn.engine.name())
+		}
+	}
+	return plan, nil
+}
+
+// run takes the backup, uploads it and prunes old backups, returning the backup file path
+func (j *backupJob) run(ctx context.Context) (string, error) {
+	if cause := interruption(ctx); cause != nil {
+		return "", cause
+	}
+	plan, err := j.prepare(ctx)
+	if err != nil {
+		return "", err
+	}
+	backupFile, err := plan.backup(ctx)
+	if err != nil {
+		return "", fmt.Errorf("database backup failed: %w", err)
+	}
+	if err := plan.upload(ctx, backupFile); err != nil {
+		return backupFile, fmt.Errorf("backup upload failed: %w", err)
+	}
+	if err := plan.prune(); err != nil {
+		return backupFile, fmt.Errorf("pruning old backups failed: %w", err)
+	}
+	return backupFile, nil
+}
+
+// backup dumps the database directly, through the SSH tunnel or on the remote host
+func (p *backupPlan) backup(ctx context.Context) (string, error) {
+	if p.job.RemoteExec {
+		return backupDatabaseRemoteExec(ctx, p.conn, p.job.Path, p.remoteUser, p.remoteHost, p.remoteDBPort, p.pipeline, p.dump)
+	}
+	if p.job.Remote != "" {
+		return backupDatabaseRemote(ctx, p.conn, p.job.LocalPort, p.job.Path, p.remoteUser, p.remoteHost, p.remoteDBPort, p.pipeline, p.dump)
+	}
+	return backupDatabaseLocal(ctx, p.conn, p.job.Path, p.pipeline, p.dump)
+}
+
+// upload copies the backup to object storage when an upload destination is set
+func (p *backupPlan) upload(ctx context.Context, backupFile string) error {
+	if p.job.Upload == "" {
+		return nil
+	}
+	return uploadBackup(ctx, backupFile, p.job.Upload)
+}
+
+// prune applies the retention policy to the database's backups when one is set
+func (p *backupPlan) prune() error {
+	if !p.retention.enabled() {
+		return nil
+	}
+	return pruneBackups(p.job.Path, p.job.backupName(p.conn), p.retention, false)
+}
+
+// backupName returns the name the job's backup files start with and are pruned
+// by: the job name, so jobs backing up databases of the same name into one
+// directory keep apart, or the database name for jobs without one
+func (j *backupJob) backupName(conn *dbConnection) string {
+	if j.Name == "" {
+		return conn.dbName
+	}
+	return safeFileName(j.Name)
+}
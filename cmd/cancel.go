@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	// childStopTimeout is how long a child process gets to exit after SIGTERM before it is killed
+	childStopTimeout = 10 * time.Second
+	// cleanupTimeout bounds the cleanup that needs the database after a command was interrupted
+	cleanupTimeout = time.Minute
+)
+
+// errInterrupted is the cause of a command context cancelled by SIGINT or SIGTERM
+var errInterrupted = errors.New("interrupted")
+
+// interruptedBy holds the number of the signal that interrupted the command, or 0
+var interruptedBy atomic.Int32
+
+// signalContext returns the context every command runs with. It is cancelled
+// on the first SIGINT or SIGTERM, so commands stop their child processes, close
+// their tunnels and remove partial files before exiting; a second signal exits
+// at once.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(signals)
+		select {
+		case sig := <-signals:
+			number := sig.(syscall.Signal)
+			interruptedBy.Store(int32(number))
+			fmt.Fprintf(os.Stderr, "\n⚠️ Received %s, cleaning up (send it again to exit immediately)\n", signalName(number))
+			cancel(fmt.Errorf("%w by %s", errInterrupted, signalName(number)))
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() { cancel(context.Canceled) }
+}
+
+// signalName names the signals that interrupt commands
+func signalName(sig syscall.Signal) string {
+	if sig == syscall.SIGINT {
+		return "SIGINT"
+	}
+	return "SIGTERM"
+}
+
+// exitCode returns the exit status of a failed command: 128 plus the signal
+// number when it was interrupted, as shells report it, and code otherwise
+func exitCode(code int) int {
+	if sig := interruptedBy.Load(); sig != 0 {
+		return 128 + int(sig)
+	}
+	return code
+}
+
+// interruption returns why ctx was cancelled, or nil while it is still live.
+// Errors of child processes killed on cancellation are replaced with it.
+func interruption(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	return context.Cause(ctx)
+}
+
+// commandContext prepares a child process that gets SIGTERM when ctx is
+// cancelled and is killed if it has not exited childStopTimeout later
+func commandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = childStopTimeout
+	return cmd
+}
+
+// cleanupContext returns a context for cleaning up after ctx, which still
+// works once ctx was cancelled but is bounded by cleanupTimeout
+func cleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+}
+
+// contextReader stops a long read, such as a copy of a local backup file, once ctx is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := interruption(r.ctx); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
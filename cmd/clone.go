@@ -0,0 +1,311 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// cloneCmd represents the command to copy a database between servers
+var cloneCmd = &cobra.Command{
+	Use: "clone <source_db_config|profile> <target_db_config|profile>",
+	Short: `Copy a PostgreSQL database into another one by piping pg_dump into pg_restore, without a file in between.
+
+		Either side is a db_config, a secret reference or the name of a
+		profile in the config file. Each side may be reached through its own
+		SSH hop with --source-remote and --target-remote.
+
+		--create creates the target database, --drop drops an existing one
+		first; otherwise the dump is loaded into the existing target
+
+		--exclude-table-data copies the definition but not the rows of
+		matching tables; the other dump filters of db backup work too
+
+		A target that is a protected profile, or the same database as one,
+		is only overwritten after typing its database name at the prompt or
+		passing it with --confirm.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		logger := createCustomLogger()
+		logger.Info("🚀 Starting database clone")
+
+		if err := checkProgressMode(progressFlag); err != nil {
+			logger.Fatalf("❌ Invalid --progress: %v", err)
+		}
+		if err := cloneFlags.filters.validate(); err != nil {
+			logger.Fatalf("❌ Invalid object filters: %v", err)
+		}
+
+		config, err := loadConfig()
+		if err != nil {
+			logger.Fatalf("❌ Failed to load config: %v", err)
+		}
+		source, err := resolveCloneEndpoint(ctx, config.Profiles, args[0], cloneSourceRemoteFlag)
+		if err != nil {
+			logger.Fatalf("❌ Invalid source: %v", err)
+		}
+		target, err := resolveCloneEndpoint(ctx, config.Profiles, args[1], cloneTargetRemoteFlag)
+		if err != nil {
+			logger.Fatalf("❌ Invalid target: %v", err)
+		}
+		if source.address() == target.address() {
+			logger.Fatalf("❌ The source and the target are the same database")
+		}
+		if err := source.conn.engine.checkDump(dumpOptions{filters: cloneFlags.filters}); err != nil {
+			logger.Fatalf("❌ Invalid object filters: %v", err)
+		}
+
+		if profile := protectedProfile(ctx, config.Profiles, target); profile != "" {
+			if err := confirmClone(target, profile); err != nil {
+				logger.Fatalf("❌ Clone not confirmed: %v", err)
+			}
+		}
+
+		if err := cloneDatabase(ctx, source, target, cloneFlags); err != nil {
+			logger.Fatalf("❌ Database clone failed: %v", err)
+		}
+
+		logger.Info("✅ Database clone completed successfully")
+	},
+}
+
+// cloneOptions controls how db clone loads the source's dump into the target
+type cloneOptions struct {
+	create bool
+	drop   bool
+	// noOwner leaves object ownership to the target's user, for targets without the source's roles
+	noOwner bool
+	filters dumpFilters
+}
+
+var (
+	cloneFlags            cloneOptions
+	cloneSourceRemoteFlag string
+	cloneTargetRemoteFlag string
+	cloneConfirmFlag      string
+)
+
+func init() {
+	dbCmd.AddCommand(cloneCmd)
+	cloneCmd.Flags().StringVar(&cloneSourceRemoteFlag, "source-remote", "", "Reach the source through an SSH tunnel, in format [<user>@]<host>[[:<ssh_port>]:<db_port>]")
+	cloneCmd.Flags().StringVar(&cloneTargetRemoteFlag, "target-remote", "", "Reach the target through an SSH tunnel, in format [<user>@]<host>[[:<ssh_port>]:<db_port>]")
+	cloneCmd.Flags().BoolVar(&cloneFlags.create, "create", false, "Create the target database before loading the dump")
+	cloneCmd.Flags().BoolVar(&cloneFlags.drop, "drop", false, "Drop the target database if it exists and create it again")
+	cloneCmd.Flags().BoolVar(&cloneFlags.noOwner, "no-owner", false, "Do not restore object ownership, for targets without the source's roles")
+	cloneCmd.Flags().StringVar(&cloneConfirmFlag, "confirm", "", "Database name of a protected target, to overwrite it without a prompt")
+	addDumpFilterFlags(cloneCmd, &cloneFlags.filters)
+	cloneCmd.Flags().StringVar(&progressFlag, "progress", "auto", "Progress reporting on stderr: auto, live, log, json or none")
+}
+
+// cloneEndpoint is the source or the target of a clone
+type cloneEndpoint struct {
+	conn *dbConnection
+	// profile is the config profile the endpoint was named by, if any
+	profile   string
+	protected bool
+
+	remoteUser   string
+	remoteHost   string
+	remoteDBPort string
+}
+
+// resolveCloneEndpoint resolves a clone argument, a profile name or a
+// db_config, and the SSH hop given for it, which overrides the profile's
+func resolveCloneEndpoint(ctx context.Context, profiles map[string]dbProfile, arg, remote string) (*cloneEndpoint, error) {
+	endpoint := &cloneEndpoint{}
+	dbConfig, password := arg, passwordSource{}
+	if profile, ok := profiles[arg]; ok {
+		endpoint.profile, endpoint.protected = arg, profile.Protected
+		dbConfig, password = profile.DB, profile.Password
+		if remote == "" {
+			remote = profile.Remote
+		}
+	}
+
+	conn, err := resolveDBConfig(ctx, dbConfig)
+	if err != nil {
+		return nil, fmt.Errorf("database configuration: %w", err)
+	}
+	if _, ok := conn.engine.(cloneEngine); !ok {
+		return nil, fmt.Errorf("cloning is not supported for %s databases", conn.engine.name())
+	}
+	if err := password.apply(ctx, conn); err != nil {
+		return nil, fmt.Errorf("database password: %w", err)
+	}
+	endpoint.conn = conn
+
+	if remote != "" {
+		endpoint.remoteUser, endpoint.remoteHost, endpoint.remoteDBPort, err = parseRemoteFlag(remote)
+		if err != nil {
+			return nil, fmt.Errorf("remote: %w", err)
+		}
+		if endpoint.remoteDBPort == "" {
+			endpoint.remoteDBPort = conn.port
+		}
+	}
+	return endpoint, nil
+}
+
+// address identifies the endpoint's database by its SSH hop, host, port and name
+func (e *cloneEndpoint) address() string {
+	port := e.conn.port
+	if e.remoteHost != "" {
+		port = e.remoteDBPort
+	}
+	return fmt.Sprintf("%s/%s:%s/%s", e.remoteHost, e.conn.remoteHost(), port, e.conn.dbName)
+}
+
+// String describes the endpoint for log output
+func (e *cloneEndpoint) String() string {
+	if e.remoteHost == "" {
+		return e.conn.String()
+	}
+	return fmt.Sprintf("%s via %s", e.conn, sshTarget(e.remoteUser, e.remoteHost))
+}
+
+// connect opens the endpoint's SSH tunnel when it has a hop and returns the
+// connection to use, with a function that closes the tunnel
+func (e *cloneEndpoint) connect(ctx context.Context) (*dbConnection, func(), error) {
+	if e.remoteHost == "" {
+		return e.conn, func() {}, nil
+	}
+	tunnel, err := startSSHTunnel(ctx, "", e.conn.remoteHost(), e.remoteUser, e.remoteHost, e.remoteDBPort)
+	if err != nil {
+		return nil, nil, err
+	}
+	return e.conn.withAddress("127.0.0.1", tunnel.localPort()), func() { tunnel.Close() }, nil
+}
+
+// protectedProfile returns the protected profile the target is, or points at
+// the same database as, or "" when it is not protected
+func protectedProfile(ctx context.Context, profiles map[string]dbProfile, target *cloneEndpoint) string {
+	if target.protected {
+		return target.profile
+	}
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !profiles[name].Protected {
+			continue
+		}
+		conn, err := resolveDBConfig(ctx, profiles[name].DB)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️ Cannot tell whether the target is the protected profile %s: %v\n", name, err)
+			continue
+		}
+		profile := &cloneEndpoint{conn: conn}
+		if remote := profiles[name].Remote; remote != "" {
+			_, profile.remoteHost, profile.remoteDBPort, _ = parseRemoteFlag(remote)
+			if profile.remoteDBPort == "" {
+				profile.remoteDBPort = conn.port
+			}
+		}
+		if profile.address() == target.address() {
+			return name
+		}
+	}
+	return ""
+}
+
+// confirmClone asks for the database name before a protected target is
+// overwritten, unless --confirm already gives it
+func confirmClone(target *cloneEndpoint, profile string) error {
+	dbName := target.conn.dbName
+	if cloneConfirmFlag != "" {
+		if cloneConfirmFlag != dbName {
+			return fmt.Errorf("--confirm %s does not match the target database %s", cloneConfirmFlag, dbName)
+		}
+		return nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("the target is the protected profile %s, pass --confirm %s to overwrite it", profile, dbName)
+	}
+
+	fmt.Fprintf(os.Stderr, "⚠️ The target %s is the protected profile %s.\nType the database name %s to overwrite it: ", target, profile, dbName)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read the confirmation: %w", err)
+	}
+	if strings.TrimSpace(answer) != dbName {
+		return fmt.Errorf("the answer does not match %s, nothing was changed", dbName)
+	}
+	return nil
+}
+
+// cloneDatabase opens the tunnels of both sides, prepares the target and
+// pipes the source's dump into it as it is written
+func cloneDatabase(ctx context.Context, sourceEndpoint, targetEndpoint *cloneEndpoint, opts cloneOptions) error {
+	source, closeSource, err := sourceEndpoint.connect(ctx)
+	if err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+	defer closeSource()
+	target, closeTarget, err := targetEndpoint.connect(ctx)
+	if err != nil {
+		return fmt.Errorf("target: %w", err)
+	}
+	defer closeTarget()
+
+	if opts.create || opts.drop {
+		if err := target.engine.createDatabase(ctx, target, opts.drop); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("🔗 Cloning %s into %s\n", sourceEndpoint, targetEndpoint)
+	// Nothing is stored, so pg_dump need not compress the stream
+	dumpOpts := dumpOptions{filters: opts.filters, compressed: true}
+	progress := dumpProgress(ctx, "clone", source, dumpOpts)
+	dumpOpts.progress = progress
+	dumpCtx, stopDump := context.WithCancel(ctx)
+	defer stopDump()
+
+	dump, err := source.engine.dump(dumpCtx, source, dumpOpts)
+	if err != nil {
+		progress.finish(err)
+		return err
+	}
+	stream := &dumpStream{Reader: io.TeeReader(dump, progress)}
+	err = target.engine.(cloneEngine).restoreStream(ctx, target, stream, opts)
+	if err != nil && !stream.ended {
+		// The target gave up early, stop the dump instead of reading it to its end
+		stopDump()
+	}
+	dumpErr := dump.Close()
+	// A dump that failed is why the restore failed, unless the restore stopped reading first
+	if dumpErr != nil && (err == nil || stream.ended) {
+		err = dumpErr
+	}
+	progress.finish(err)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Cloned %s into database %s\n", source.dbName, target.dbName)
+	return nil
+}
+
+// dumpStream remembers whether the dump was read to its end
+type dumpStream struct {
+	io.Reader
+	ended bool
+}
+
+func (s *dumpStream) Read(p []byte) (int, error) {
+	n, err := s.Reader.Read(p)
+	if err == io.EOF {
+		s.ended = true
+	}
+	return n, err
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestCloneEndpointAddress(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint cloneEndpoint
+		want     string
+	}{
+		{
+			name:     "local",
+			endpoint: cloneEndpoint{conn: &dbConnection{host: "db.example.com", port: "5432", dbName: "shop"}},
+			want:     "/db.example.com:5432/shop",
+		},
+		{
+			name:     "empty host is localhost",
+			endpoint: cloneEndpoint{conn: &dbConnection{port: "5432", dbName: "shop"}},
+			want:     "/localhost:5432/shop",
+		},
+		{
+			name:     "the SSH hop's database port replaces the connection's",
+			endpoint: cloneEndpoint{conn: &dbConnection{port: "5432", dbName: "shop"}, remoteUser: "deploy", remoteHost: "bastion:2222", remoteDBPort: "6543"},
+			want:     "bastion:2222/localhost:6543/shop",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.endpoint.address(); got != tt.want {
+				t.Errorf("address = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtectedProfile(t *testing.T) {
+	t.Setenv("PGPASSFILE", filepath.Join(t.TempDir(), "missing"))
+	profiles := map[string]dbProfile{
+		"prod":     {DB: "postgres://app@db.prod.internal:5432/shop", Protected: true},
+		"prod-hop": {DB: "postgres://app@localhost/shop", Remote: "deploy@bastion:22:5433", Protected: true},
+		"staging":  {DB: "postgres://app@db.staging.internal/shop"},
+		"broken":   {DB: "env://OMTI_TEST_UNSET_DB", Protected: true},
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		remote string
+		want   string
+	}{
+		{name: "protected profile", target: "prod", want: "prod"},
+		{name: "db_config of a protected profile", target: "postgres://admin@db.prod.internal/shop", want: "prod"},
+		{name: "keyword db_config of a protected profile", target: "host=db.prod.internal port=5432 dbname=shop", want: "prod"},
+		{name: "same SSH hop and database as a protected profile", target: "dbname=shop", remote: "ops@bastion:22:5433", want: "prod-hop"},
+		{name: "unprotected profile", target: "staging"},
+		{name: "another database on a protected server", target: "postgres://app@db.prod.internal/shop_copy"},
+		{name: "another port on a protected server", target: "postgres://app@db.prod.internal:5433/shop"},
+		{name: "protected database through another hop", target: "dbname=shop", remote: "ops@other-bastion:22:5433"},
+		{name: "protected hop's database without the hop", target: "postgres://app@localhost:5433/shop"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := resolveCloneEndpoint(context.Background(), profiles, tt.target, tt.remote)
+			if err != nil {
+				t.Fatalf("resolveCloneEndpoint failed: %v", err)
+			}
+			if got := protectedProfile(context.Background(), profiles, target); got != tt.want {
+				t.Errorf("protectedProfile(%s) = %q, want %q", target.address(), got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+// compressionCodec streams backup data through a compression format
+type compressionCodec struct {
+	name string
+	// ext is appended to the backup file name, e.g. ".gz"
+	ext string
+	// magic identifies compressed data so restores can detect the codec
+	magic []byte
+	// maxLevel is the highest accepted --level; 0 always selects the codec default
+	maxLevel  int
+	newWriter func(w io.Writer, level int) (io.WriteCloser, error)
+	newReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+var compressionCodecs = map[string]compressionCodec{
+	"none": {
+		name: "none",
+		newWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+			return nopWriteCloser{w}, nil
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(r), nil
+		},
+	},
+	"gzip": {
+		name:     "gzip",
+		ext:      ".gz",
+		magic:    []byte{0x1f, 0x8b},
+		maxLevel: gzip.BestCompression,
+		newWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+			if level == 0 {
+				level = gzip.DefaultCompression
+			}
+			return gzip.NewWriterLevel(w, level)
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	"zstd": {
+		name:     "zstd",
+		ext:      ".zst",
+		magic:    []byte{0x28, 0xb5, 0x2f, 0xfd},
+		maxLevel: 22,
+		newWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+			if level == 0 {
+				return zstd.NewWriter(w)
+			}
+			return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			decoder, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return decoder.IOReadCloser(), nil
+		},
+	},
+	"lz4": {
+		name:     "lz4",
+		ext:      ".lz4",
+		magic:    []byte{0x04, 0x22, 0x4d, 0x18},
+		maxLevel: 9,
+		newWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+			writer := lz4.NewWriter(w)
+			if level > 0 {
+				if err := writer.Apply(lz4.CompressionLevelOption(lz4.CompressionLevel(1 << (8 + level)))); err != nil {
+					return nil, err
+				}
+			}
+			return writer, nil
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(lz4.NewReader(r)), nil
+		},
+	},
+}
+
+// nopWriteCloser adds a no-op Close to an io.Writer
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// lookupCodec validates a --compress / --level combination
+func lookupCodec(name string, level int) (compressionCodec, error) {
+	codec, ok := compressionCodecs[name]
+	if !ok {
+		names := make([]string, 0, len(compressionCodecs))
+		for n := range compressionCodecs {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return compressionCodec{}, fmt.Errorf("unknown compression codec %q, use one of %s", name, strings.Join(names, ", "))
+	}
+	if level < 0 || level > codec.maxLevel {
+		if codec.maxLevel == 0 {
+			return compressionCodec{}, fmt.Errorf("--level is not supported with --compress %s", name)
+		}
+		return compressionCodec{}, fmt.Errorf("--level for %s must be between 1 and %d", name, codec.maxLevel)
+	}
+	return codec, nil
+}
+
+// detectCodec identifies the compression codec of a stream by its magic bytes
+// without consuming them, falling back to "none"
+func detectCodec(r *bufio.Reader) compressionCodec {
+	for _, codec := range compressionCodecs {
+		if len(codec.magic) == 0 {
+			continue
+		}
+		head, err := r.Peek(len(codec.magic))
+		if err == nil && bytes.Equal(head, codec.magic) {
+			return codec
+		}
+	}
+	return compressionCodecs["none"]
+}
+
+// decompressingReader wraps r with the decompressor matching its detected codec
+func decompressingReader(r io.Reader) (io.ReadCloser, compressionCodec, error) {
+	buffered := bufio.NewReader(r)
+	codec := detectCodec(buffered)
+	reader, err := codec.newReader(buffered)
+	if err != nil {
+		return nil, codec, fmt.Errorf("failed to open %s stream: %w", codec.name, err)
+	}
+	return reader, codec, nil
+}
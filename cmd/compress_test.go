@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// sampleDump is compressible test data standing in for dump output
+var sampleDump = []byte(strings.Repeat("INSERT INTO orders VALUES (1, 'widget', 9.99);\n", 2000))
+
+func TestCompressionRoundTrip(t *testing.T) {
+	for name, codec := range compressionCodecs {
+		for _, level := range []int{0, codec.maxLevel} {
+			var buf bytes.Buffer
+			writer, err := codec.newWriter(&buf, level)
+			if err != nil {
+				t.Fatalf("%s level %d: newWriter failed: %v", name, level, err)
+			}
+			if _, err := writer.Write(sampleDump); err != nil {
+				t.Fatalf("%s level %d: write failed: %v", name, level, err)
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("%s level %d: close failed: %v", name, level, err)
+			}
+			if !bytes.HasPrefix(buf.Bytes(), codec.magic) {
+				t.Errorf("%s level %d: output starts with %x, want magic %x", name, level, buf.Bytes()[:4], codec.magic)
+			}
+			if name != "none" && buf.Len() >= len(sampleDump) {
+				t.Errorf("%s level %d: compressed %d bytes into %d", name, level, len(sampleDump), buf.Len())
+			}
+
+			reader, detected, err := decompressingReader(&buf)
+			if err != nil {
+				t.Fatalf("%s level %d: decompressingReader failed: %v", name, level, err)
+			}
+			if detected.name != name {
+				t.Errorf("%s level %d: detected codec %s", name, level, detected.name)
+			}
+			got, err := io.ReadAll(reader)
+			reader.Close()
+			if err != nil {
+				t.Fatalf("%s level %d: read failed: %v", name, level, err)
+			}
+			if !bytes.Equal(got, sampleDump) {
+				t.Errorf("%s level %d: round trip returned %d bytes, want the %d written", name, level, len(got), len(sampleDump))
+			}
+		}
+	}
+}
+
+func TestDetectCodec(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+		want  string
+	}{
+		{name: "pg_dump archive", input: []byte("PGDMP\x01\x0f"), want: "none"},
+		{name: "plain SQL", input: []byte("--\n-- PostgreSQL database dump\n"), want: "none"},
+		{name: "shorter than every magic", input: []byte{0x1f}, want: "none"},
+		{name: "empty", want: "none"},
+		{name: "gzip", input: []byte{0x1f, 0x8b, 0x08, 0x00}, want: "gzip"},
+		{name: "zstd", input: []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00}, want: "zstd"},
+		{name: "lz4", input: []byte{0x04, 0x22, 0x4d, 0x18, 0x00}, want: "lz4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(bytes.NewReader(tt.input))
+			if got := detectCodec(r); got.name != tt.want {
+				t.Errorf("detectCodec = %s, want %s", got.name, tt.want)
+			}
+			// Detection must leave the stream untouched
+			if rest, _ := io.ReadAll(r); !bytes.Equal(rest, tt.input) {
+				t.Errorf("detectCodec consumed input, %x left of %x", rest, tt.input)
+			}
+		})
+	}
+}
+
+func TestLookupCodec(t *testing.T) {
+	tests := []struct {
+		name    string
+		codec   string
+		level   int
+		wantErr string
+	}{
+		{name: "default level", codec: "zstd"},
+		{name: "highest level", codec: "gzip", level: 9},
+		{name: "lz4 level", codec: "lz4", level: 9},
+		{name: "none", codec: "none"},
+		{name: "unknown codec", codec: "bzip2", wantErr: `unknown compression codec "bzip2", use one of gzip, lz4, none, zstd`},
+		{name: "level too high", codec: "zstd", level: 23, wantErr: "--level for zstd must be between 1 and 22"},
+		{name: "negative level", codec: "gzip", level: -1, wantErr: "--level for gzip must be between 1 and 9"},
+		{name: "level without compression", codec: "none", level: 1, wantErr: "--level is not supported with --compress none"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codec, err := lookupCodec(tt.codec, tt.level)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("lookupCodec(%q, %d) error = %v, want %q", tt.codec, tt.level, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("lookupCodec(%q, %d) failed: %v", tt.codec, tt.level, err)
+			}
+			if codec.name != tt.codec {
+				t.Errorf("lookupCodec(%q, %d) = %s", tt.codec, tt.level, codec.name)
+			}
+		})
+	}
+}
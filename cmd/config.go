@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// omtiConfig is the optional configuration file, $HOME/.omti.yaml unless --config is given
+type omtiConfig struct {
+	S3         s3Config             `yaml:"s3"`
+	Vault      vaultConfig          `yaml:"vault"`
+	Profiles   map[string]dbProfile `yaml:"profiles"`
+	BackupJobs []scheduledJob       `yaml:"backup_jobs"`
+}
+
+// s3Config holds the connection settings for S3-compatible object storage
+type s3Config struct {
+	// Endpoint is a host[:port] or URL; an http:// URL disables TLS, e.g. for a local MinIO
+	Endpoint        string `yaml:"endpoint"`
+	Region          string `yaml:"region"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	SessionToken    string `yaml:"session_token"`
+	// PathStyle forces path-style bucket addressing instead of virtual hosts
+	PathStyle bool `yaml:"path_style"`
+}
+
+// vaultConfig holds the connection settings for HashiCorp Vault, used to
+// resolve secret://vault/... references
+type vaultConfig struct {
+	// Address is the Vault server URL, e.g. https://vault.example.com:8200
+	Address   string `yaml:"address"`
+	Token     string `yaml:"token"`
+	Namespace string `yaml:"namespace"`
+}
+
+// dbProfile is a named database, reached through an optional SSH hop
+type dbProfile struct {
+	DB       string         `yaml:"db"`
+	Password passwordSource `yaml:",inline"`
+	Remote   string         `yaml:"remote"`
+	// Protected asks for confirmation before db clone overwrites the database
+	Protected bool `yaml:"protected"`
+}
+
+// loadConfig reads the configuration file. A missing default file yields an empty
+// configuration, while a missing file passed with --config is an error.
+func loadConfig() (*omtiConfig, error) {
+	path := cfgFile
+	if path == "" {
+		path = filepath.Join(os.Getenv("HOME"), ".omti.yaml")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && cfgFile == "" {
+			return &omtiConfig{}, nil
+		}
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var config omtiConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return &config, nil
+}
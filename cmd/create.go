@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -18,16 +19,17 @@ var createCmd = &cobra.Command{
 		repoName := args[0]
 		folderPath := args[1]
 
+		ctx := cmd.Context()
 		logger := createCustomLogger()
 		logger.Info("🚀 Starting the repository creation process")
 
 		// Run all necessary environment checks before proceeding
-		if err := checkEnvironment(); err != nil {
+		if err := checkEnvironment(ctx); err != nil {
 			logger.Fatalf("❌ Environment checks failed: %v", err)
 		}
 
 		// Check if the repository already exists
-		exists, err := repoExists(repoName)
+		exists, err := repoExists(ctx, repoName)
 		if err != nil {
 			logger.Fatalf("❌ Failed to check if repository exists: %v", err)
 		}
@@ -36,21 +38,21 @@ var createCmd = &cobra.Command{
 		} else {
 			// Create new GitHub repository if it doesn't exist
 			logger.Infof("Creating new GitHub repository: %s", repoName)
-			if err := createRepo(repoName); err != nil {
+			if err := createRepo(ctx, repoName); err != nil {
 				logger.Fatalf("❌ Failed to create GitHub repository: %v", err)
 			}
 			logger.Infof("✅ Repository %s created successfully", repoName)
 		}
 
 		// Check if the repository is empty (has no commits) before pushing
-		isEmpty, err := repoIsEmpty(repoName)
+		isEmpty, err := repoIsEmpty(ctx, repoName)
 		if err != nil {
 			logger.Fatalf("❌ Failed to check if repository is empty: %v", err)
 		}
 		if isEmpty {
 			// Push folder to GitHub as the first commit if the repo is empty
 			logger.Infof("Pushing %s to GitHub", folderPath)
-			if err := pushToRepo(repoName, folderPath); err != nil {
+			if err := pushToRepo(ctx, repoName, folderPath); err != nil {
 				logger.Fatalf("❌ Failed to push folder to repository: %v", err)
 			}
 			logger.Info("✅ Folder pushed successfully")
@@ -67,9 +69,12 @@ func init() {
 }
 
 // repoExists checks if the GitHub repository already exists
-func repoExists(repoName string) (bool, error) {
-	cmd := exec.Command("gh", "repo", "view", repoName)
+func repoExists(ctx context.Context, repoName string) (bool, error) {
+	cmd := commandContext(ctx, "gh", "repo", "view", repoName)
 	if err := cmd.Run(); err != nil {
+		if cause := interruption(ctx); cause != nil {
+			return false, cause
+		}
 		// If the command fails with an exit code, the repository likely doesn't exist
 		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() != 0 {
 			return false, nil
@@ -80,9 +85,9 @@ func repoExists(repoName string) (bool, error) {
 }
 
 // repoIsEmpty checks if the GitHub repository has no commits (is empty)
-func repoIsEmpty(repoName string) (bool, error) {
+func repoIsEmpty(ctx context.Context, repoName string) (bool, error) {
 	// Run the `gh` command to retrieve repository information
-	cmd := exec.Command("gh", "repo", "view", repoName, "--json", "defaultBranchRef")
+	cmd := commandContext(ctx, "gh", "repo", "view", repoName, "--json", "defaultBranchRef")
 	output, err := cmd.Output()
 	if err != nil {
 		return false, fmt.Errorf("failed to retrieve repository info: %w", err)
@@ -105,27 +110,27 @@ func repoIsEmpty(repoName string) (bool, error) {
 }
 
 // createRepo creates a new repository on GitHub using the GitHub CLI
-func createRepo(repoName string) error {
-	return runCommand("gh", "repo", "create", repoName, "--public", "--source=.", "--remote=origin")
+func createRepo(ctx context.Context, repoName string) error {
+	return runCommand(ctx, "gh", "repo", "create", repoName, "--public", "--source=.", "--remote=origin")
 }
 
 // pushToRepo initializes and pushes a local folder to the GitHub repository
-func pushToRepo(repoName, folderPath string) error {
+func pushToRepo(ctx context.Context, repoName, folderPath string) error {
 	if err := os.Chdir(folderPath); err != nil {
 		return fmt.Errorf("failed to change directory: %w", err)
 	}
 
-	if err := runCommand("git", "init"); err != nil {
+	if err := runCommand(ctx, "git", "init"); err != nil {
 		return err
 	}
-	if err := runCommand("git", "add", "."); err != nil {
+	if err := runCommand(ctx, "git", "add", "."); err != nil {
 		return err
 	}
-	if err := runCommand("git", "commit", "-m", "Initial commit"); err != nil {
+	if err := runCommand(ctx, "git", "commit", "-m", "Initial commit"); err != nil {
 		return err
 	}
-	if err := runCommand("git", "branch", "-M", "main"); err != nil {
+	if err := runCommand(ctx, "git", "branch", "-M", "main"); err != nil {
 		return err
 	}
-	return runCommand("git", "push", "-u", "origin", "main")
+	return runCommand(ctx, "git", "push", "-u", "origin", "main")
 }
@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// dbConnection holds the connection settings shared by every db subcommand
+type dbConnection struct {
+	user     string
+	password string
+	host     string
+	port     string
+	dbName   string
+	// path is the database file of file-based engines such as SQLite
+	path string
+	// params holds additional client parameters such as sslmode
+	params map[string]string
+	// engine backs up and restores the database
+	engine dbEngine
+}
+
+var (
+	keywordDSNPattern = regexp.MustCompile(`^\s*[A-Za-z_]+\s*=`)
+	uriSchemePattern  = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9+.-]*)://`)
+)
+
+// parseDBConfig parses a database configuration given in one of these formats:
+//
+//	<username>:<password>@<host>:<port>/<dbname>
+//	postgres://<username>:<password>@<host>:<port>/<dbname>?sslmode=require
+//	mysql://<username>:<password>@<host>:<port>/<dbname>?ssl-mode=REQUIRED
+//	sqlite:///<path>
+//	host=<host> port=<port> user=<username> password=<password> dbname=<dbname>
+//
+// The URI scheme selects the database engine; the other formats are PostgreSQL.
+func parseDBConfig(config string) (*dbConnection, error) {
+	var conn *dbConnection
+	var err error
+
+	switch match := uriSchemePattern.FindStringSubmatch(config); {
+	case match != nil:
+		engine, ok := dbEngines[strings.ToLower(match[1])]
+		if !ok {
+			return nil, fmt.Errorf("unsupported database scheme %q", match[1])
+		}
+		if parser, ok := engine.(uriParser); ok {
+			conn, err = parser.parseURI(config)
+		} else {
+			conn, err = parseDBURI(config)
+		}
+		if err == nil {
+			conn.engine = engine
+		}
+	case keywordDSNPattern.MatchString(config):
+		conn, err = parseKeywordDSN(config)
+	default:
+		conn, err = parseShortDBConfig(config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if conn.engine == nil {
+		conn.engine = postgresEngine{}
+	}
+	if conn.port == "" {
+		conn.port = conn.engine.defaultPort()
+	}
+	if conn.dbName == "" {
+		return nil, fmt.Errorf("missing database name")
+	}
+	for key := range conn.params {
+		if err := conn.engine.checkParam(key); err != nil {
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// parseDBURI parses a database URI such as postgres://..., percent-decoding every component
+func parseDBURI(config string) (*dbConnection, error) {
+	u, err := url.Parse(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection URI: %w", err)
+	}
+
+	conn := &dbConnection{
+		host:   u.Hostname(),
+		port:   u.Port(),
+		dbName: strings.TrimPrefix(u.Path, "/"),
+		params: map[string]string{},
+	}
+	if u.User != nil {
+		conn.user = u.User.Username()
+		conn.password, _ = u.User.Password()
+	}
+
+	for key, values := range u.Query() {
+		if err := conn.set(key, values[len(values)-1]); err != nil {
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// parseKeywordDSN parses a libpq keyword/value string such as "host=db port=5432 dbname=app",
+// where values may be single-quoted with backslash escapes
+func parseKeywordDSN(config string) (*dbConnection, error) {
+	conn := &dbConnection{params: map[string]string{}}
+
+	rest := strings.TrimSpace(config)
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("missing \"=\" after %q in connection string", rest)
+		}
+		key := strings.TrimSpace(rest[:eq])
+		rest = strings.TrimLeft(rest[eq+1:], " \t")
+
+		var value strings.Builder
+		if strings.HasPrefix(rest, "'") {
+			i := 1
+			for ; i < len(rest) && rest[i] != '\''; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			if i >= len(rest) {
+				return nil, fmt.Errorf("unterminated quoted value for %q in connection string", key)
+			}
+			rest = rest[i+1:]
+		} else {
+			i := 0
+			for ; i < len(rest) && rest[i] != ' ' && rest[i] != '\t'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			rest = rest[i:]
+		}
+		rest = strings.TrimSpace(rest)
+
+		if err := conn.set(key, value.String()); err != nil {
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// parseShortDBConfig parses the short <username>[:<password>]@<host>:<port>/<dbname> format.
+// The password may contain ':' and '@', and IPv6 hosts are written in brackets.
+// It is left out when it comes from --password-file or another source.
+func parseShortDBConfig(config string) (*dbConnection, error) {
+	at := strings.LastIndex(config, "@")
+	if at < 0 {
+		return nil, fmt.Errorf("missing or invalid user and host format")
+	}
+
+	userPass, hostPortDB := config[:at], config[at+1:]
+	user, password, _ := strings.Cut(userPass, ":")
+	if user == "" {
+		return nil, fmt.Errorf("missing or invalid username and password format")
+	}
+
+	hostPort, dbName, ok := strings.Cut(hostPortDB, "/")
+	if !ok {
+		return nil, fmt.Errorf("missing or invalid database name format")
+	}
+
+	host, port := hostPort, ""
+	if strings.HasPrefix(hostPort, "[") && strings.HasSuffix(hostPort, "]") {
+		host = hostPort[1 : len(hostPort)-1]
+	} else if strings.HasPrefix(hostPort, "[") || strings.Count(hostPort, ":") == 1 {
+		var err error
+		host, port, err = net.SplitHostPort(hostPort)
+		if err != nil {
+			return nil, fmt.Errorf("missing or invalid host and port format: %w", err)
+		}
+	}
+
+	return &dbConnection{
+		user:     user,
+		password: password,
+		host:     host,
+		port:     port,
+		dbName:   dbName,
+		params:   map[string]string{},
+	}, nil
+}
+
+// set assigns a connection keyword to the matching field or extra parameter
+func (c *dbConnection) set(key, value string) error {
+	switch key {
+	case "host", "hostaddr":
+		c.host = value
+	case "port":
+		c.port = value
+	case "user":
+		c.user = value
+	case "password":
+		c.password = value
+	case "dbname":
+		c.dbName = value
+	default:
+		if key == "" {
+			return fmt.Errorf("empty keyword in connection string")
+		}
+		c.params[key] = value
+	}
+	return nil
+}
+
+// withAddress returns a copy of the connection pointed at a different host and port,
+// e.g. the local end of an SSH tunnel
+func (c *dbConnection) withAddress(host, port string) *dbConnection {
+	clone := *c
+	clone.host = host
+	clone.port = port
+	return &clone
+}
+
+// remoteHost returns the database host as seen from the SSH jump host
+func (c *dbConnection) remoteHost() string {
+	if c.host == "" {
+		return "localhost"
+	}
+	return c.host
+}
+
+// env returns the environment for the engine's client tools and a function
+// that cleans up after them
+func (c *dbConnection) env() ([]string, func(), error) {
+	return c.engine.env(c)
+}
+
+// String describes the connection for log output without revealing the password
+func (c *dbConnection) String() string {
+	if c.path != "" {
+		return c.path
+	}
+	host := c.host
+	if host == "" {
+		host = "localhost"
+	}
+	return fmt.Sprintf("%s@%s/%s", c.user, net.JoinHostPort(host, c.port), c.dbName)
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// encryptedExt is appended to the name of encrypted backup files
+const encryptedExt = ".age"
+
+// ageHeader starts every binary age file
+var ageHeader = []byte("age-encryption.org/v1\n")
+
+// encryptionRecipients builds the age recipients for --encrypt-to public keys or a --passphrase-file.
+// It returns nil when the backup should not be encrypted.
+func encryptionRecipients(recipientKeys []string, passphraseFile string) ([]age.Recipient, error) {
+	if len(recipientKeys) > 0 && passphraseFile != "" {
+		return nil, fmt.Errorf("--encrypt-to and --passphrase-file cannot be combined")
+	}
+
+	if passphraseFile != "" {
+		passphrase, err := readPassphraseFile(passphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		recipient, err := age.NewScryptRecipient(passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive key from passphrase: %w", err)
+		}
+		return []age.Recipient{recipient}, nil
+	}
+
+	var recipients []age.Recipient
+	for _, key := range recipientKeys {
+		recipient, err := age.ParseX25519Recipient(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --encrypt-to recipient %q: %w", key, err)
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+// decryptionIdentities loads the age identities from an --identity file or a --passphrase-file
+func decryptionIdentities(identityFile, passphraseFile string) ([]age.Identity, error) {
+	var identities []age.Identity
+
+	if identityFile != "" {
+		file, err := os.Open(identityFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open identity file: %w", err)
+		}
+		defer file.Close()
+
+		parsed, err := age.ParseIdentities(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse identity file %s: %w", identityFile, err)
+		}
+		identities = append(identities, parsed...)
+	}
+
+	if passphraseFile != "" {
+		passphrase, err := readPassphraseFile(passphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		identity, err := age.NewScryptIdentity(passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive key from passphrase: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+	return identities, nil
+}
+
+// readPassphraseFile reads a passphrase from the first line of a file
+func readPassphraseFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase file: %w", err)
+	}
+	passphrase, _, _ := strings.Cut(string(data), "\n")
+	passphrase = strings.TrimSuffix(passphrase, "\r")
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase file %s is empty", path)
+	}
+	return passphrase, nil
+}
+
+// backupArchive describes how a backup file is stored on disk
+type backupArchive struct {
+	encrypted bool
+	codec     compressionCodec
+}
+
+// transformed reports whether the file has to be decrypted or decompressed before the restore tool can read it
+func (a backupArchive) transformed() bool {
+	return a.encrypted || a.codec.name != "none"
+}
+
+// String describes the archive's packaging, e.g. "encrypted zstd backup"
+func (a backupArchive) String() string {
+	description := "backup"
+	if !a.transformed() {
+		return "plain " + description
+	}
+	if a.codec.name != "none" {
+		description = a.codec.name + " " + description
+	}
+	if a.encrypted {
+		description = "encrypted " + description
+	}
+	return description
+}
+
+// openBackupArchive returns a reader over the plain pg_dump archive stored in a
+// backup file, decrypting with identities and decompressing as needed
+func openBackupArchive(r io.Reader, identities []age.Identity) (io.ReadCloser, backupArchive, error) {
+	var archive backupArchive
+
+	buffered := bufio.NewReader(r)
+	if head, err := buffered.Peek(len(ageHeader)); err == nil && bytes.Equal(head, ageHeader) {
+		if len(identities) == 0 {
+			return nil, archive, fmt.Errorf("backup is encrypted, pass --identity or --passphrase-file to decrypt it")
+		}
+		decrypted, err := age.Decrypt(buffered, identities...)
+		if err != nil {
+			return nil, archive, fmt.Errorf("failed to decrypt backup: %w", err)
+		}
+		archive.encrypted = true
+		r = decrypted
+	} else {
+		r = buffered
+	}
+
+	reader, codec, err := decompressingReader(r)
+	if err != nil {
+		return nil, archive, err
+	}
+	archive.codec = codec
+	return reader, archive, nil
+}
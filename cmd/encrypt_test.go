@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+// writePassphraseFile writes a passphrase file into a new temporary directory
+func writePassphraseFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "passphrase")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeThroughPipeline writes sampleDump through a pipeline into a new file and
+// returns the file's contents
+func writeThroughPipeline(t *testing.T, pipeline *backupPipeline) []byte {
+	file, err := os.Create(filepath.Join(t.TempDir(), "backup"+pipeline.ext()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := pipeline.write(bytes.NewReader(sampleDump), file)
+	if err != nil {
+		t.Fatalf("pipeline write failed: %v", err)
+	}
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(data)
+	if result.size != int64(len(data)) || result.checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("pipeline reported %d bytes with sha256 %s, the file has %d bytes with sha256 %x", result.size, result.checksum, len(data), sum)
+	}
+	if !bytes.Equal(result.header, sampleDump[:archiveHeaderSize]) {
+		t.Errorf("pipeline header is not the start of the plain dump")
+	}
+	return data
+}
+
+// readBackupArchive unpacks a backup written by a pipeline
+func readBackupArchive(data []byte, identities []age.Identity) ([]byte, backupArchive, error) {
+	reader, archive, err := openBackupArchive(bytes.NewReader(data), identities)
+	if err != nil {
+		return nil, archive, err
+	}
+	defer reader.Close()
+	plain, err := io.ReadAll(reader)
+	return plain, archive, err
+}
+
+func TestBackupPipelineRoundTrip(t *testing.T) {
+	first, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	passphraseFile := writePassphraseFile(t, "correct horse battery staple\r\n")
+	passphraseIdentity, err := decryptionIdentities("", passphraseFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrongPassphrase, err := decryptionIdentities("", writePassphraseFile(t, "incorrect horse\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		compress       string
+		encryptTo      []string
+		passphraseFile string
+		wantExt        string
+		wantArchive    string
+		wantEncryption string
+		// identities each decrypt the backup on their own
+		identities []age.Identity
+		// wrongIdentities must all fail to decrypt it
+		wrongIdentities [][]age.Identity
+	}{
+		{name: "plain", compress: "none", wantArchive: "plain backup", wantEncryption: "none"},
+		{name: "compressed", compress: "zstd", wantExt: ".zst", wantArchive: "zstd backup", wantEncryption: "none"},
+		{
+			name:            "recipients",
+			compress:        "gzip",
+			encryptTo:       []string{first.Recipient().String(), second.Recipient().String()},
+			wantExt:         ".gz.age",
+			wantArchive:     "encrypted gzip backup",
+			wantEncryption:  "age-recipient",
+			identities:      []age.Identity{first, second},
+			wrongIdentities: [][]age.Identity{{other}, passphraseIdentity},
+		},
+		{
+			name:            "passphrase",
+			compress:        "none",
+			passphraseFile:  passphraseFile,
+			wantExt:         ".age",
+			wantArchive:     "encrypted backup",
+			wantEncryption:  "age-passphrase",
+			identities:      passphraseIdentity,
+			wrongIdentities: [][]age.Identity{wrongPassphrase, {first}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline, err := newBackupPipeline(tt.compress, 0, tt.encryptTo, tt.passphraseFile)
+			if err != nil {
+				t.Fatalf("newBackupPipeline failed: %v", err)
+			}
+			if pipeline.ext() != tt.wantExt || pipeline.encryption() != tt.wantEncryption {
+				t.Errorf("pipeline ext, encryption = %q, %q, want %q, %q", pipeline.ext(), pipeline.encryption(), tt.wantExt, tt.wantEncryption)
+			}
+			data := writeThroughPipeline(t, pipeline)
+			if encrypted := bytes.HasPrefix(data, ageHeader); encrypted != (tt.wantEncryption != "none") {
+				t.Errorf("backup starts with the age header: %v, want %v", encrypted, !encrypted)
+			}
+
+			identities := [][]age.Identity{nil}
+			if len(tt.identities) > 0 {
+				identities = nil
+				for _, identity := range tt.identities {
+					identities = append(identities, []age.Identity{identity})
+				}
+			}
+			for _, ids := range identities {
+				plain, archive, err := readBackupArchive(data, ids)
+				if err != nil {
+					t.Fatalf("reading the backup failed: %v", err)
+				}
+				if !bytes.Equal(plain, sampleDump) {
+					t.Errorf("round trip returned %d bytes, want the %d written", len(plain), len(sampleDump))
+				}
+				if archive.String() != tt.wantArchive {
+					t.Errorf("archive = %q, want %q", archive, tt.wantArchive)
+				}
+			}
+
+			for _, ids := range tt.wrongIdentities {
+				if _, _, err := readBackupArchive(data, ids); err == nil || !strings.Contains(err.Error(), "failed to decrypt backup") {
+					t.Errorf("reading with the wrong identity: error = %v, want failed to decrypt backup", err)
+				}
+			}
+			if len(tt.identities) > 0 {
+				if _, _, err := readBackupArchive(data, nil); err == nil || !strings.Contains(err.Error(), "pass --identity or --passphrase-file") {
+					t.Errorf("reading without identities: error = %v, want a hint to pass --identity", err)
+				}
+			}
+		})
+	}
+}
+
+func TestEncryptionRecipients(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	passphraseFile := writePassphraseFile(t, "secret\n")
+
+	tests := []struct {
+		name           string
+		encryptTo      []string
+		passphraseFile string
+		want           int
+		wantErr        string
+	}{
+		{name: "no encryption"},
+		{name: "recipient", encryptTo: []string{identity.Recipient().String()}, want: 1},
+		{name: "passphrase", passphraseFile: passphraseFile, want: 1},
+		{name: "both", encryptTo: []string{identity.Recipient().String()}, passphraseFile: passphraseFile, wantErr: "--encrypt-to and --passphrase-file cannot be combined"},
+		{name: "invalid recipient", encryptTo: []string{"age1notakey"}, wantErr: `invalid --encrypt-to recipient "age1notakey"`},
+		{name: "identity instead of recipient", encryptTo: []string{identity.String()}, wantErr: "invalid --encrypt-to recipient"},
+		{name: "missing passphrase file", passphraseFile: filepath.Join(t.TempDir(), "missing"), wantErr: "failed to read passphrase file"},
+		{name: "empty passphrase file", passphraseFile: writePassphraseFile(t, "\nsecret\n"), wantErr: "is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recipients, err := encryptionRecipients(tt.encryptTo, tt.passphraseFile)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("encryptionRecipients error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("encryptionRecipients failed: %v", err)
+			}
+			if len(recipients) != tt.want {
+				t.Errorf("encryptionRecipients returned %d recipients, want %d", len(recipients), tt.want)
+			}
+		})
+	}
+}
+
+func TestDecryptionIdentities(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	identityFile := filepath.Join(dir, "key.txt")
+	content := "# created: 2024-01-01T00:00:00Z\n# public key: " + identity.Recipient().String() + "\n" + identity.String() + "\n"
+	if err := os.WriteFile(identityFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	invalidFile := filepath.Join(dir, "invalid.txt")
+	if err := os.WriteFile(invalidFile, []byte("not a key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	identities, err := decryptionIdentities(identityFile, writePassphraseFile(t, "secret\n"))
+	if err != nil {
+		t.Fatalf("decryptionIdentities failed: %v", err)
+	}
+	if len(identities) != 2 {
+		t.Errorf("decryptionIdentities returned %d identities, want the key and the passphrase", len(identities))
+	}
+	if _, err := decryptionIdentities(invalidFile, ""); err == nil || !strings.Contains(err.Error(), "failed to parse identity file") {
+		t.Errorf("decryptionIdentities error = %v, want failed to parse identity file", err)
+	}
+	if _, err := decryptionIdentities(filepath.Join(dir, "missing.txt"), ""); err == nil || !strings.Contains(err.Error(), "failed to open identity file") {
+		t.Errorf("decryptionIdentities error = %v, want failed to open identity file", err)
+	}
+}
@@ -1,7 +1,7 @@
 package cmd
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -13,24 +13,24 @@ import (
 )
 
 // checkEnvironment performs all necessary checks for `gh`, `git`, and SSH key setup
-func checkEnvironment() error {
+func checkEnvironment(ctx context.Context) error {
 	logger := createCustomLogger()
 
 	logger.Info("🔍 Starting environment checks")
 
 	// Check for GitHub CLI
-	if err := checkGH(); err != nil {
-		logger.Fatalf("❌ GitHub CLI setup failed: %v", err)
+	if err := checkGH(ctx); err != nil {
+		return fmt.Errorf("GitHub CLI setup failed: %w", err)
 	}
 
 	// Check for Git
-	if err := checkGit(); err != nil {
-		logger.Fatalf("❌ Git setup failed: %v", err)
+	if err := checkGit(ctx); err != nil {
+		return fmt.Errorf("Git setup failed: %w", err)
 	}
 
 	// Ensure SSH key is set up
-	if err := ensureSSHKey(); err != nil {
-		logger.Fatalf("❌ SSH key setup failed: %v", err)
+	if err := ensureSSHKey(ctx); err != nil {
+		return fmt.Errorf("SSH key setup failed: %w", err)
 	}
 
 	logger.Info("✅ All environment checks passed")
@@ -38,10 +38,10 @@ func checkEnvironment() error {
 }
 
 // checkGH ensures the GitHub CLI is installed, installing it if necessary
-func checkGH() error {
+func checkGH(ctx context.Context) error {
 	if !isCommandAvailable("gh") {
 		fmt.Println("Installing GitHub CLI...")
-		if err := installGH(); err != nil {
+		if err := installGH(ctx); err != nil {
 			return fmt.Errorf("failed to install GitHub CLI: %w", err)
 		}
 		fmt.Println("✅ GitHub CLI installed")
@@ -52,10 +52,10 @@ func checkGH() error {
 }
 
 // checkGit ensures Git is installed, installing it if necessary
-func checkGit() error {
+func checkGit(ctx context.Context) error {
 	if !isCommandAvailable("git") {
 		fmt.Println("Installing Git...")
-		if err := installGit(); err != nil {
+		if err := installGit(ctx); err != nil {
 			return fmt.Errorf("failed to install Git: %w", err)
 		}
 		fmt.Println("✅ Git installed")
@@ -66,38 +66,38 @@ func checkGit() error {
 }
 
 // ensureSSHKey generates an SSH key if it doesn't exist and adds it to GitHub
-func ensureSSHKey() error {
+func ensureSSHKey(ctx context.Context) error {
 	sshKeyPath := filepath.Join(os.Getenv("HOME"), ".ssh", "id_rsa.pub")
 	if _, err := os.Stat(sshKeyPath); os.IsNotExist(err) {
 		fmt.Println("Generating SSH key...")
-		if err := generateSSHKey(); err != nil {
+		if err := generateSSHKey(ctx); err != nil {
 			return fmt.Errorf("failed to generate SSH key: %w", err)
 		}
 		fmt.Println("✅ SSH key generated")
 	}
-	return addSSHKeyToGitHub(sshKeyPath)
+	return addSSHKeyToGitHub(ctx, sshKeyPath)
 }
 
 // generateSSHKey creates a new SSH key pair
-func generateSSHKey() error {
+func generateSSHKey(ctx context.Context) error {
 	sshDir := filepath.Join(os.Getenv("HOME"), ".ssh")
 	os.MkdirAll(sshDir, 0700)
 
-	cmd := exec.Command("ssh-keygen", "-t", "rsa", "-b", "4096", "-f", filepath.Join(sshDir, "id_rsa"), "-N", "")
+	cmd := commandContext(ctx, "ssh-keygen", "-t", "rsa", "-b", "4096", "-f", filepath.Join(sshDir, "id_rsa"), "-N", "")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
 
 // addSSHKeyToGitHub adds the SSH key to GitHub using the GitHub CLI
-func addSSHKeyToGitHub(sshKeyPath string) error {
+func addSSHKeyToGitHub(ctx context.Context, sshKeyPath string) error {
 	_, err := os.ReadFile(sshKeyPath)
 	if err != nil {
 		return fmt.Errorf("failed to read SSH key: %w", err)
 	}
 
 	fmt.Println("Adding SSH key to GitHub...")
-	if err := runCommand("gh", "ssh-key", "add", sshKeyPath, "--title", "omti-cli-key"); err != nil {
+	if err := runCommand(ctx, "gh", "ssh-key", "add", sshKeyPath, "--title", "omti-cli-key"); err != nil {
 		return fmt.Errorf("failed to add SSH key to GitHub: %w", err)
 	}
 	fmt.Println("✅ SSH key added to GitHub")
@@ -105,30 +105,30 @@ func addSSHKeyToGitHub(sshKeyPath string) error {
 }
 
 // installGH installs GitHub CLI based on the operating system
-func installGH() error {
+func installGH(ctx context.Context) error {
 	switch runtime.GOOS {
 	case "darwin":
-		return runCommand("brew", "install", "gh")
+		return runCommand(ctx, "brew", "install", "gh")
 	case "linux":
-		if err := runCommand("sudo", "apt", "update"); err != nil {
+		if err := runCommand(ctx, "sudo", "apt", "update"); err != nil {
 			return err
 		}
-		return runCommand("sudo", "apt", "install", "-y", "gh")
+		return runCommand(ctx, "sudo", "apt", "install", "-y", "gh")
 	default:
 		return fmt.Errorf("unsupported OS: %s", runtime.GOOS)
 	}
 }
 
 // installGit installs Git based on the operating system
-func installGit() error {
+func installGit(ctx context.Context) error {
 	switch runtime.GOOS {
 	case "darwin":
-		return runCommand("brew", "install", "git")
+		return runCommand(ctx, "brew", "install", "git")
 	case "linux":
-		if err := runCommand("sudo", "apt", "update"); err != nil {
+		if err := runCommand(ctx, "sudo", "apt", "update"); err != nil {
 			return err
 		}
-		return runCommand("sudo", "apt", "install", "-y", "git")
+		return runCommand(ctx, "sudo", "apt", "install", "-y", "git")
 	default:
 		return fmt.Errorf("unsupported OS: %s", runtime.GOOS)
 	}
@@ -147,11 +147,16 @@ func createCustomLogger() *logrus.Logger {
 		DisableTimestamp: true,
 		ForceColors:      true,
 	})
+	// Fatal errors of interrupted commands exit with the signal's status
+	logger.ExitFunc = func(code int) {
+		os.Exit(exitCode(code))
+	}
 	return logger
 }
 
-func runCommand(name string, args ...string) error {
-	cmd := exec.Command(name, args...)
+// runCommand runs a command with its output on the terminal, stopping it when ctx is cancelled
+func runCommand(ctx context.Context, name string, args ...string) error {
+	cmd := commandContext(ctx, name, args...)
 
 	// Log the command if in debug mode
 	if logrus.GetLevel() == logrus.DebugLevel {
@@ -160,17 +165,21 @@ func runCommand(name string, args ...string) error {
 
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	err := cmd.Run()
+	if cause := interruption(ctx); cause != nil {
+		return fmt.Errorf("%s stopped: %w", name, cause)
+	}
+	return err
 }
 
 // ensurePgDump checks if pg_dump is installed locally, logs the version, and installs it if necessary
-func ensurePgDump() error {
-	cmd := exec.Command("pg_dump", "--version")
+func ensurePgDump(ctx context.Context) error {
+	cmd := commandContext(ctx, "pg_dump", "--version")
 	output, err := cmd.Output()
 	if err != nil {
 		// Attempt to install pg_dump if not found
 		fmt.Println("pg_dump not found, attempting to install...")
-		return installPgDump()
+		return installPgDump(ctx)
 	}
 
 	// Log the pg_dump version
@@ -179,34 +188,16 @@ func ensurePgDump() error {
 }
 
 // installPgDump installs pg_dump based on the operating system
-func installPgDump() error {
+func installPgDump(ctx context.Context) error {
 	switch runtime.GOOS {
 	case "darwin":
-		return runCommand("brew", "install", "postgresql")
+		return runCommand(ctx, "brew", "install", "postgresql")
 	case "linux":
-		if err := runCommand("sudo", "apt", "update"); err != nil {
+		if err := runCommand(ctx, "sudo", "apt", "update"); err != nil {
 			return err
 		}
-		return runCommand("sudo", "apt", "install", "-y", "postgresql-client")
+		return runCommand(ctx, "sudo", "apt", "install", "-y", "postgresql-client")
 	default:
 		return fmt.Errorf("unsupported OS: %s", runtime.GOOS)
 	}
 }
-
-// killProcessOnPort kills any process using the specified port.
-func killProcessOnPort(port string) error {
-	cmd := exec.Command("lsof", "-t", "-i", fmt.Sprintf(":%s", port))
-	output, err := cmd.Output()
-	if err != nil {
-		return fmt.Errorf("failed to find process on port %s: %w", port, err)
-	}
-
-	// Get PID from the output and kill the process
-	pid := string(bytes.TrimSpace(output))
-	if pid == "" {
-		return fmt.Errorf("no process found on port %s", port)
-	}
-
-	killCmd := exec.Command("kill", pid)
-	return killCmd.Run()
-}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// dumpFilters restricts a dump to some of the database's objects. The patterns
+// follow pg_dump's -n/-N/-t/-T syntax, so wildcards such as audit_* work.
+type dumpFilters struct {
+	Schemas          []string `yaml:"schemas" json:"schemas,omitempty"`
+	ExcludeSchemas   []string `yaml:"exclude_schemas" json:"exclude_schemas,omitempty"`
+	Tables           []string `yaml:"tables" json:"tables,omitempty"`
+	ExcludeTables    []string `yaml:"exclude_tables" json:"exclude_tables,omitempty"`
+	ExcludeTableData []string `yaml:"exclude_table_data" json:"exclude_table_data,omitempty"`
+	SchemaOnly       bool     `yaml:"schema_only" json:"schema_only,omitempty"`
+	DataOnly         bool     `yaml:"data_only" json:"data_only,omitempty"`
+}
+
+// dumpOptions controls what a dump contains and how the dump tool writes it
+type dumpOptions struct {
+	// format is the engine's dump format, e.g. "plain"; empty selects the default
+	format string
+	// jobs is the number of parallel dump jobs for directory dumps
+	jobs int
+	// compressed is set when the backup pipeline compresses the dump itself
+	compressed bool
+	filters    dumpFilters
+	// progress reports the dump's progress; nil when it is not reported
+	progress *progressReporter
+	// name starts the backup's file name and groups it for retention, the job
+	// name or, when empty, the database name
+	name string
+}
+
+var dumpFiltersFlag dumpFilters
+
+// addDumpFilterFlags registers the object filter flags on a command
+func addDumpFilterFlags(cmd *cobra.Command, filters *dumpFilters) {
+	cmd.Flags().StringArrayVar(&filters.Schemas, "schema", nil, "Dump only schemas matching this pattern, can be repeated")
+	cmd.Flags().StringArrayVar(&filters.ExcludeSchemas, "exclude-schema", nil, "Do not dump schemas matching this pattern, can be repeated")
+	cmd.Flags().StringArrayVar(&filters.Tables, "table", nil, "Dump only tables matching this pattern, can be repeated")
+	cmd.Flags().StringArrayVar(&filters.ExcludeTables, "exclude-table", nil, "Do not dump tables matching this pattern, can be repeated")
+	cmd.Flags().StringArrayVar(&filters.ExcludeTableData, "exclude-table-data", nil, "Dump the definition but not the rows of tables matching this pattern, can be repeated")
+	cmd.Flags().BoolVar(&filters.SchemaOnly, "schema-only", false, "Dump only object definitions, no data")
+	cmd.Flags().BoolVar(&filters.DataOnly, "data-only", false, "Dump only data, no object definitions")
+}
+
+// empty reports whether the filters leave the dump unrestricted
+func (f dumpFilters) empty() bool {
+	return len(f.Schemas) == 0 && len(f.ExcludeSchemas) == 0 &&
+		len(f.Tables) == 0 && len(f.ExcludeTables) == 0 && len(f.ExcludeTableData) == 0 &&
+		!f.SchemaOnly && !f.DataOnly
+}
+
+// validate rejects contradictory filters
+func (f dumpFilters) validate() error {
+	if f.SchemaOnly && f.DataOnly {
+		return fmt.Errorf("--schema-only and --data-only cannot be combined")
+	}
+	for _, patterns := range [][]string{f.Schemas, f.ExcludeSchemas, f.Tables, f.ExcludeTables, f.ExcludeTableData} {
+		for _, pattern := range patterns {
+			if pattern == "" {
+				return fmt.Errorf("filter patterns must not be empty")
+			}
+		}
+	}
+	return nil
+}
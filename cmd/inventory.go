@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// backupInventory lists the databases backed up by db backup --inventory
+type backupInventory struct {
+	Backups []backupJob `yaml:"backups"`
+}
+
+// inventoryResult is the outcome of one inventory job
+type inventoryResult struct {
+	job        backupJob
+	backupFile string
+	duration   time.Duration
+	err        error
+}
+
+// loadInventory reads an inventory file. Jobs without a name are named after
+// their database, resolving secret references to find it.
+func loadInventory(ctx context.Context, path string) ([]backupJob, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inventory: %w", err)
+	}
+
+	var inventory backupInventory
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&inventory); err != nil {
+		return nil, fmt.Errorf("failed to parse inventory %s: %w", path, err)
+	}
+	if len(inventory.Backups) == 0 {
+		return nil, fmt.Errorf("inventory %s lists no backups", path)
+	}
+
+	// Backup files are named after their job, jobs writing to one directory need distinct names
+	seen := map[string]string{}
+	for i := range inventory.Backups {
+		job := &inventory.Backups[i]
+		if job.Name == "" {
+			conn, err := resolveDBConfig(ctx, job.DB)
+			if err != nil {
+				return nil, fmt.Errorf("backup %d has no name and its database name cannot be read, give it a name: %w", i+1, err)
+			}
+			job.Name = conn.dbName
+		}
+
+		key := filepath.Clean(job.Path) + "\x00" + safeFileName(job.Name)
+		if other, ok := seen[key]; ok {
+			return nil, fmt.Errorf("jobs %q and %q would name their backups in %s alike, give them distinct names, e.g. after their host", other, job.Name, job.Path)
+		}
+		seen[key] = job.Name
+	}
+	return inventory.Backups, nil
+}
+
+// runInventory runs the jobs with at most parallel of them at a time and returns
+// their results in inventory order. Jobs that have not started when ctx is
+// cancelled fail without running.
+func runInventory(ctx context.Context, jobs []backupJob, parallel int, logger *logrus.Logger) []inventoryResult {
+	results := make([]inventoryResult, len(jobs))
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+
+	for i := range jobs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			job := jobs[i]
+			jobLogger := logger.WithField("job", job.Name)
+			jobLogger.Info("🚀 Starting backup")
+
+			start := time.Now()
+			backupFile, err := job.run(ctx)
+			results[i] = inventoryResult{
+				job:        job,
+				backupFile: backupFile,
+				duration:   time.Since(start),
+				err:        err,
+			}
+
+			if err != nil {
+				jobLogger.Errorf("❌ Backup failed: %v", err)
+			} else {
+				jobLogger.Info("✅ Backup completed")
+			}
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+// printInventorySummary prints a table of the job results
+func printInventorySummary(results []inventoryResult) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "JOB\tRESULT\tDURATION\tSIZE\tBACKUP / ERROR")
+	for _, result := range results {
+		status, size, detail := "✅ ok", "-", result.backupFile
+		if result.backupFile != "" {
+			if info, err := os.Stat(result.backupFile); err == nil {
+				size = formatBytes(info.Size())
+			}
+		}
+		if result.err != nil {
+			status = "❌ failed"
+			detail, _, _ = strings.Cut(result.err.Error(), "\n")
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", result.job.Name, status, result.duration.Round(time.Second), size, detail)
+	}
+	writer.Flush()
+}
+
+// formatBytes formats a byte count with a binary unit, e.g. "1.5 GiB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// listCmd represents the command to list the backups in a directory or S3 location
+var listCmd = &cobra.Command{
+	Use:   "list [local_save_path|s3://<bucket>/<prefix>]",
+	Short: "List the backups in a directory or S3 location",
+	Long: `List the backups in a directory (default: the current directory) or S3 location,
+newest first, with their database, creation time, age, size and status.
+
+The status compares each backup with its manifest:
+  ok          the file size matches the manifest
+  verified    the SHA-256 checksum matches the manifest (--checksum)
+  corrupt     the size or checksum does not match the manifest
+  missing     the manifest's backup file is gone
+  unverified  the backup has no manifest
+  partial     the temporary file of a backup that is still running or was
+              abandoned by a crashed run; remove it if no backup is running
+
+--since and --until take a date (2006-01-02, inclusive) or an RFC 3339 time.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		location := "."
+		if len(args) == 1 {
+			location = args[0]
+		}
+
+		ctx := cmd.Context()
+		logger := createCustomLogger()
+
+		if listOutput != "table" && listOutput != "json" {
+			logger.Fatalf("❌ Invalid --output %q, expected table or json", listOutput)
+		}
+		since, err := parseDateFlag(listSince, false)
+		if err != nil {
+			logger.Fatalf("❌ Invalid --since: %v", err)
+		}
+		until, err := parseDateFlag(listUntil, true)
+		if err != nil {
+			logger.Fatalf("❌ Invalid --until: %v", err)
+		}
+
+		var backups []backupEntry
+		if isS3URL(location) {
+			backups, err = listS3Backups(ctx, location, listDBName)
+		} else {
+			backups, err = listBackups(location, listDBName)
+		}
+		if err != nil {
+			logger.Fatalf("❌ Failed to list backups: %v", err)
+		}
+
+		listings := listBackupEntries(ctx, backups, since, until, listChecksum)
+		if cause := interruption(ctx); cause != nil {
+			logger.Fatalf("❌ Listing backups stopped: %v", cause)
+		}
+		if listOutput == "json" {
+			err = printBackupListingsJSON(listings)
+		} else {
+			printBackupListings(listings)
+		}
+		if err != nil {
+			logger.Fatalf("❌ Failed to print backups: %v", err)
+		}
+	},
+}
+
+// backupListing is one row of db backup list
+type backupListing struct {
+	Database    string    `json:"database"`
+	File        string    `json:"file"`
+	CreatedAt   time.Time `json:"created_at"`
+	AgeSeconds  int64     `json:"age_seconds"`
+	SizeBytes   int64     `json:"size_bytes"`
+	Engine      string    `json:"engine,omitempty"`
+	Compression string    `json:"compression,omitempty"`
+	Encryption  string    `json:"encryption,omitempty"`
+	Status      string    `json:"status"`
+}
+
+var (
+	listDBName   string
+	listSince    string
+	listUntil    string
+	listOutput   string
+	listChecksum bool
+)
+
+func init() {
+	backupCmd.AddCommand(listCmd)
+	listCmd.Flags().StringVar(&listDBName, "db", "", "Only list backups of this database or job")
+	listCmd.Flags().StringVar(&listSince, "since", "", "Only list backups taken at or after this date or time")
+	listCmd.Flags().StringVar(&listUntil, "until", "", "Only list backups taken at or before this date or time")
+	listCmd.Flags().StringVarP(&listOutput, "output", "o", "table", "Output format: table or json")
+	listCmd.Flags().BoolVar(&listChecksum, "checksum", false, "Read every backup and compare its SHA-256 checksum with the manifest")
+}
+
+// parseDateFlag parses a 2006-01-02 date or an RFC 3339 time. A date stands for
+// the start of that day, or for the end of it when endOfDay is set.
+func parseDateFlag(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither a date (2006-01-02) nor an RFC 3339 time", value)
+	}
+	if endOfDay {
+		return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	return day, nil
+}
+
+// listBackupEntries turns the backups taken between since and until into
+// listings, newest first. A zero since or until leaves that end open.
+func listBackupEntries(ctx context.Context, backups []backupEntry, since, until time.Time, checksum bool) []backupListing {
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].timestamp.After(backups[j].timestamp)
+	})
+
+	listings := []backupListing{}
+	now := time.Now()
+	for _, backup := range backups {
+		if !since.IsZero() && backup.timestamp.Before(since) {
+			continue
+		}
+		if !until.IsZero() && backup.timestamp.After(until) {
+			continue
+		}
+
+		listing := backupListing{
+			Database:   backup.dbName,
+			File:       backup.path,
+			CreatedAt:  backup.timestamp,
+			AgeSeconds: int64(now.Sub(backup.timestamp).Seconds()),
+			SizeBytes:  backup.size,
+			Status:     backupStatus(ctx, backup, checksum),
+		}
+		if backup.size < 0 {
+			listing.SizeBytes = 0
+		}
+		if m := backup.manifest; m != nil {
+			listing.Engine = m.Engine
+			if listing.Engine == "" {
+				listing.Engine = "postgres"
+			}
+			listing.Compression = m.Compression
+			listing.Encryption = m.Encryption
+		}
+		listings = append(listings, listing)
+	}
+	return listings
+}
+
+// backupStatus checks a backup against its manifest, reading the whole file
+// to compare checksums when checksum is set
+func backupStatus(ctx context.Context, backup backupEntry, checksum bool) string {
+	switch {
+	case backup.partial:
+		return "partial"
+	case backup.manifest == nil:
+		return "unverified"
+	case backup.size < 0:
+		return "missing"
+	case backup.size != backup.manifest.SizeBytes:
+		return "corrupt"
+	case !checksum:
+		return "ok"
+	}
+
+	sum, err := backupChecksum(ctx, backup.path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return "unreadable"
+	}
+	if sum != backup.manifest.SHA256 {
+		return "corrupt"
+	}
+	return "verified"
+}
+
+// printBackupListings prints the listings as a table
+func printBackupListings(listings []backupListing) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "DATABASE\tCREATED\tAGE\tSIZE\tSTATUS\tFILE")
+	for _, listing := range listings {
+		size := "-"
+		if listing.Status != "missing" {
+			size = formatBytes(listing.SizeBytes)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			listing.Database,
+			formatTime(listing.CreatedAt),
+			formatAge(time.Duration(listing.AgeSeconds)*time.Second),
+			size,
+			listing.Status,
+			path.Base(listing.File),
+		)
+	}
+	writer.Flush()
+}
+
+// printBackupListingsJSON prints the listings as a JSON array
+func printBackupListingsJSON(listings []backupListing) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(listings)
+}
+
+// formatAge formats a duration coarsely, e.g. "3d 4h", "5h 12m" or "7m"
+func formatAge(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	days := int(d / (24 * time.Hour))
+	hours := int(d / time.Hour % 24)
+	minutes := int(d / time.Minute % 60)
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// checksumOf returns the hex SHA-256 checksum of data
+func checksumOf(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestBackupStatus(t *testing.T) {
+	dir := t.TempDir()
+	backupFile := filepath.Join(dir, "golang_backup_20240101_030000.dump")
+	if err := os.WriteFile(backupFile, []byte("dump"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	intact := &backupManifest{SizeBytes: 4, SHA256: checksumOf("dump")}
+	altered := &backupManifest{SizeBytes: 4, SHA256: checksumOf("pump")}
+
+	tests := []struct {
+		name     string
+		backup   backupEntry
+		checksum bool
+		want     string
+	}{
+		{name: "partial", backup: backupEntry{path: backupFile, size: 4, partial: true}, want: "partial"},
+		{name: "no manifest", backup: backupEntry{path: backupFile, size: 4}, want: "unverified"},
+		{name: "no manifest with checksum", backup: backupEntry{path: backupFile, size: 4}, checksum: true, want: "unverified"},
+		{name: "file missing", backup: backupEntry{path: backupFile + ".gone", size: -1, manifest: intact}, want: "missing"},
+		{name: "size differs", backup: backupEntry{path: backupFile, size: 3, manifest: intact}, want: "corrupt"},
+		{name: "size matches", backup: backupEntry{path: backupFile, size: 4, manifest: altered}, want: "ok"},
+		{name: "checksum matches", backup: backupEntry{path: backupFile, size: 4, manifest: intact}, checksum: true, want: "verified"},
+		{name: "checksum differs", backup: backupEntry{path: backupFile, size: 4, manifest: altered}, checksum: true, want: "corrupt"},
+		{name: "unreadable", backup: backupEntry{path: filepath.Join(dir, "vanished.dump"), size: 4, manifest: intact}, checksum: true, want: "unreadable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := backupStatus(context.Background(), tt.backup, tt.checksum); got != tt.want {
+				t.Errorf("backupStatus = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDateFlag(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	defer func(local *time.Location) { time.Local = local }(time.Local)
+	time.Local = newYork
+
+	tests := []struct {
+		value    string
+		endOfDay bool
+		want     time.Time
+		wantErr  bool
+	}{
+		{value: ""},
+		{value: "", endOfDay: true},
+		{value: "2024-03-10", want: time.Date(2024, 3, 10, 0, 0, 0, 0, newYork)},
+		// The day daylight saving time starts has 23 hours
+		{value: "2024-03-10", endOfDay: true, want: time.Date(2024, 3, 10, 23, 59, 59, 999999999, newYork)},
+		{value: "2024-03-10T12:30:00Z", want: time.Date(2024, 3, 10, 12, 30, 0, 0, time.UTC)},
+		{value: "2024-03-10T12:30:00+02:00", endOfDay: true, want: time.Date(2024, 3, 10, 10, 30, 0, 0, time.UTC)},
+		{value: "2024-02-30", wantErr: true},
+		{value: "10/03/2024", wantErr: true},
+		{value: "2024-03-10 12:30", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseDateFlag(tt.value, tt.endOfDay)
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "is neither a date") {
+				t.Errorf("parseDateFlag(%q) error = %v, want is neither a date", tt.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseDateFlag(%q) failed: %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseDateFlag(%q, %v) = %v, want %v", tt.value, tt.endOfDay, got, tt.want)
+		}
+	}
+}
+
+func TestListBackupEntries(t *testing.T) {
+	at := func(day int) time.Time { return time.Date(2024, 3, day, 3, 0, 0, 0, time.UTC) }
+	backups := []backupEntry{
+		{path: "golang_backup_20240301_030000.dump", dbName: "golang", timestamp: at(1), size: 10},
+		{path: "golang_backup_20240303_030000.dump", dbName: "golang", timestamp: at(3), size: -1, manifest: &backupManifest{SizeBytes: 30, Compression: "zstd"}},
+		{path: "golang_backup_20240302_030000.dump", dbName: "golang", timestamp: at(2), size: 20, manifest: &backupManifest{Engine: "mysql", SizeBytes: 20, Encryption: "age-recipient"}},
+	}
+
+	tests := []struct {
+		name         string
+		since, until time.Time
+		want         []string
+	}{
+		{name: "all, newest first", want: []string{"03 missing 0 postgres zstd", "02 ok 20 mysql age-recipient", "01 unverified 10"}},
+		{name: "since is inclusive", since: at(2), want: []string{"03 missing 0 postgres zstd", "02 ok 20 mysql age-recipient"}},
+		{name: "until is inclusive", until: at(2), want: []string{"02 ok 20 mysql age-recipient", "01 unverified 10"}},
+		{name: "empty range", since: at(4)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, l := range listBackupEntries(context.Background(), backups, tt.since, tt.until, false) {
+				row := fmt.Sprintf("%s %s %d %s %s %s", l.CreatedAt.Format("02"), l.Status, l.SizeBytes, l.Engine, l.Compression, l.Encryption)
+				got = append(got, strings.Join(strings.Fields(row), " "))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("listBackupEntries = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
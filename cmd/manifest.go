@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// manifestSuffix is appended to a backup file name to form its manifest file name
+const manifestSuffix = ".json"
+
+// backupManifest describes a backup file and is written next to it as <backup>.json.
+// Engine is empty in manifests written before engines other than postgres were
+// supported; DumpVersion records the dump tool version of those other engines.
+// Filters is only set for dumps restricted to some of the database's objects.
+type backupManifest struct {
+	File            string       `json:"file"`
+	Name            string       `json:"name,omitempty"`
+	Engine          string       `json:"engine,omitempty"`
+	Database        string       `json:"database"`
+	Host            string       `json:"host"`
+	Port            string       `json:"port"`
+	Remote          string       `json:"remote,omitempty"`
+	Format          string       `json:"format"`
+	Compression     string       `json:"compression"`
+	Encryption      string       `json:"encryption"`
+	Filters         *dumpFilters `json:"filters,omitempty"`
+	PgDumpVersion   string       `json:"pg_dump_version,omitempty"`
+	DumpVersion     string       `json:"dump_version,omitempty"`
+	ServerVersion   string       `json:"server_version,omitempty"`
+	StartedAt       time.Time    `json:"started_at"`
+	FinishedAt      time.Time    `json:"finished_at"`
+	DurationSeconds float64      `json:"duration_seconds"`
+	SizeBytes       int64        `json:"size_bytes"`
+	SHA256          string       `json:"sha256"`
+}
+
+// manifestPath returns the manifest file path for a backup file
+func manifestPath(backupFile string) string {
+	return backupFile + manifestSuffix
+}
+
+// backupPath returns the backup file described by a manifest stored at path
+func (m *backupManifest) backupPath(path string) string {
+	return filepath.Join(filepath.Dir(path), m.File)
+}
+
+// writeManifest stores the manifest next to the backup file, replacing it atomically
+func writeManifest(backupFile string, m *backupManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := writeFileAtomic(manifestPath(backupFile), append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// readManifest loads a manifest file
+func readManifest(path string) (*backupManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", path, err)
+	}
+	return decodeManifest(data, path)
+}
+
+// decodeManifest parses the contents of the manifest stored at path
+func decodeManifest(data []byte, path string) (*backupManifest, error) {
+	var m backupManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	if m.File == "" || m.Database == "" {
+		return nil, fmt.Errorf("manifest %s is missing the file or database name", path)
+	}
+	// The file is deleted when the backup is pruned, so it must stay next to the manifest
+	if m.File != filepath.Base(m.File) || m.File == "." || m.File == ".." {
+		return nil, fmt.Errorf("manifest %s names an invalid backup file %q", path, m.File)
+	}
+	return &m, nil
+}
+
+// loadBackupManifest reads the manifest stored next to a local or S3-hosted
+// backup file, returning nil if the backup has none
+func loadBackupManifest(ctx context.Context, backupFile string) (*backupManifest, error) {
+	location := manifestPath(backupFile)
+	source, err := openBackupSource(ctx, location)
+	if err != nil {
+		var s3Err minio.ErrorResponse
+		if errors.Is(err, fs.ErrNotExist) || (errors.As(err, &s3Err) && s3Err.Code == "NoSuchKey") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer source.Close()
+
+	data, err := io.ReadAll(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", location, err)
+	}
+	return decodeManifest(data, location)
+}
+
+// backupChecksum computes the SHA-256 checksum of a local or S3-hosted backup
+// file, or the tree checksum of a directory dump
+func backupChecksum(ctx context.Context, location string) (string, error) {
+	if info, err := os.Stat(location); err == nil && info.IsDir() {
+		_, checksum, err := dirChecksum(location)
+		return checksum, err
+	}
+
+	source, err := openBackupSource(ctx, location)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, source); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", location, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// dirChecksum returns the total size of the files in a directory dump and its
+// tree checksum: the SHA-256 of a sha256sum-style listing of every file, sorted by path
+func dirChecksum(dir string) (int64, string, error) {
+	var size int64
+	var listing strings.Builder
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		hash := sha256.New()
+		n, err := io.Copy(hash, file)
+		if err != nil {
+			return err
+		}
+		size += n
+		fmt.Fprintf(&listing, "%x  %s\n", hash.Sum(nil), filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	sum := sha256.Sum256([]byte(listing.String()))
+	return size, hex.EncodeToString(sum[:]), nil
+}
+
+// dirSize returns the total size of the files in a directory dump
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// pgDumpVersion returns the version number reported by the local pg_dump binary,
+// e.g. "16.2" for "pg_dump (PostgreSQL) 16.2"
+func pgDumpVersion(ctx context.Context) string {
+	output, err := commandContext(ctx, "pg_dump", "--version").Output()
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[len(fields)-1]
+}
+
+// archiveHeaderSize is how much of the pg_dump output is kept to read the archive header
+const archiveHeaderSize = 4096
+
+// headerCapture keeps the first archiveHeaderSize bytes written through it
+type headerCapture struct {
+	buf []byte
+}
+
+func (h *headerCapture) Write(p []byte) (int, error) {
+	if room := archiveHeaderSize - len(h.buf); room > 0 {
+		h.buf = append(h.buf, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// parseArchiveVersions reads the server and pg_dump versions recorded in the
+// header of a custom-format archive, returning empty strings if it cannot
+func parseArchiveVersions(head []byte) (serverVersion, dumpVersion string) {
+	if !bytes.HasPrefix(head, []byte("PGDMP")) || len(head) < 11 {
+		return "", ""
+	}
+	vmaj, vmin, intSize := head[5], head[6], int(head[8])
+	pos := 11
+
+	readInt := func() (int, bool) {
+		if intSize == 0 || pos+1+intSize > len(head) {
+			return 0, false
+		}
+		negative := head[pos] != 0
+		value := 0
+		for i := intSize - 1; i >= 0; i-- {
+			value = value<<8 | int(head[pos+1+i])
+		}
+		pos += 1 + intSize
+		if negative {
+			value = -value
+		}
+		return value, true
+	}
+	readStr := func() (string, bool) {
+		length, ok := readInt()
+		if !ok || length < 0 || pos+length > len(head) {
+			return "", false
+		}
+		str := string(head[pos : pos+length])
+		pos += length
+		return str, true
+	}
+
+	// Archive 1.15 (PostgreSQL 16) stores the compression algorithm as a single byte
+	if vmaj > 1 || vmin >= 15 {
+		pos++
+	} else if _, ok := readInt(); !ok {
+		return "", ""
+	}
+	// Creation time: seconds, minutes, hours, day, month, year, isdst
+	for i := 0; i < 7; i++ {
+		if _, ok := readInt(); !ok {
+			return "", ""
+		}
+	}
+	if _, ok := readStr(); !ok { // database name
+		return "", ""
+	}
+	serverVersion, _ = readStr()
+	dumpVersion, _ = readStr()
+	return serverVersion, dumpVersion
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+)
+
+// pgArchiveInt encodes an integer of a custom-format archive as pg_dump writes
+// it with 4-byte integers: a sign byte and the magnitude in little-endian order
+func pgArchiveInt(value int) []byte {
+	sign := byte(0)
+	if value < 0 {
+		sign, value = 1, -value
+	}
+	return []byte{sign, byte(value), byte(value >> 8), byte(value >> 16), byte(value >> 24)}
+}
+
+// pgArchivePrelude builds the header of a custom-format archive of the given
+// archive version up to the database name, where the versions follow
+func pgArchivePrelude(vmin byte) []byte {
+	head := []byte{'P', 'G', 'D', 'M', 'P', 1, vmin, 0, 4, 8, 1}
+	if vmin >= 15 {
+		head = append(head, 0) // compression algorithm
+	} else {
+		head = append(head, pgArchiveInt(-1)...) // compression level
+	}
+	for _, field := range []int{30, 15, 3, 1, 0, 124, 0} { // creation time
+		head = append(head, pgArchiveInt(field)...)
+	}
+	head = append(head, pgArchiveInt(len("golang"))...)
+	return append(head, "golang"...)
+}
+
+// pgArchiveHeader builds the header of a custom-format archive recording the versions
+func pgArchiveHeader(vmin byte, serverVersion, dumpVersion string) []byte {
+	head := pgArchivePrelude(vmin)
+	for _, version := range []string{serverVersion, dumpVersion} {
+		head = append(head, pgArchiveInt(len(version))...)
+		head = append(head, version...)
+	}
+	return append(head, "table of contents"...)
+}
+
+func TestParseArchiveVersions(t *testing.T) {
+	v14 := pgArchiveHeader(14, "15.6", "15.6")
+	v16 := pgArchiveHeader(16, "17.2 (Debian 17.2-1.pgdg120+1)", "17.2")
+	// A string length of -1 stands for a null string
+	nullServer := append(pgArchivePrelude(15), pgArchiveInt(-1)...)
+	nullServer = append(append(nullServer, pgArchiveInt(4)...), "16.1"...)
+
+	tests := []struct {
+		name       string
+		head       []byte
+		wantServer string
+		wantPgDump string
+	}{
+		{name: "archive 1.14", head: v14, wantServer: "15.6", wantPgDump: "15.6"},
+		{name: "archive 1.15", head: pgArchiveHeader(15, "16.2", "16.2"), wantServer: "16.2", wantPgDump: "16.2"},
+		{name: "archive 1.16 with a distribution version", head: v16, wantServer: "17.2 (Debian 17.2-1.pgdg120+1)", wantPgDump: "17.2"},
+		{name: "null server version", head: nullServer, wantPgDump: "16.1"},
+		{name: "cut off in the pg_dump version", head: v14[:bytes.LastIndex(v14, []byte("15.6"))+2], wantServer: "15.6"},
+		{name: "cut off in the database name", head: v14[:bytes.Index(v14, []byte("golang"))+2]},
+		{name: "cut off in the creation time", head: v14[:20]},
+		{name: "magic only", head: []byte("PGDMP")},
+		{name: "zero integer size", head: []byte{'P', 'G', 'D', 'M', 'P', 1, 14, 0, 0, 8, 1, 0, 0, 0}},
+		{name: "plain dump", head: []byte("--\n-- PostgreSQL database dump\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, pgDump := parseArchiveVersions(tt.head)
+			if server != tt.wantServer || pgDump != tt.wantPgDump {
+				t.Errorf("parseArchiveVersions = %q, %q, want %q, %q", server, pgDump, tt.wantServer, tt.wantPgDump)
+			}
+		})
+	}
+}
+
+func TestHeaderCapture(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), archiveHeaderSize/5)
+	capture := &headerCapture{}
+	for chunk := data; len(chunk) > 0; chunk = chunk[min(len(chunk), 1000):] {
+		n, err := capture.Write(chunk[:min(len(chunk), 1000)])
+		if err != nil || n != min(len(chunk), 1000) {
+			t.Fatalf("Write = %d, %v", n, err)
+		}
+	}
+	if !bytes.Equal(capture.buf, data[:archiveHeaderSize]) {
+		t.Errorf("headerCapture kept %d bytes, want the first %d", len(capture.buf), archiveHeaderSize)
+	}
+}
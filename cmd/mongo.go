@@ -0,0 +1,323 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// mongoEngine backs up MongoDB databases as archives with mongodump and
+// restores them with mongorestore. mongodump gzips the archive itself unless
+// the backup pipeline compresses it.
+type mongoEngine struct{}
+
+// mongoArchiveMagic starts every mongodump archive
+var mongoArchiveMagic = []byte{0x6d, 0xe2, 0x99, 0x81}
+
+// mongoPreludeSize is how much of an archive is read to find the database it
+// holds, enough for the header and the first collection's metadata
+const mongoPreludeSize = 64 << 10
+
+func (mongoEngine) name() string                 { return "mongodb" }
+func (mongoEngine) defaultPort() string          { return "27017" }
+func (mongoEngine) dumpExt(format string) string { return ".archive" }
+
+// checkParam accepts every parameter; they are passed on as connection string
+// options, which the MongoDB tools validate themselves
+func (mongoEngine) checkParam(key string) error {
+	return nil
+}
+
+func (mongoEngine) env(conn *dbConnection) ([]string, func(), error) {
+	return os.Environ(), func() {}, nil
+}
+
+// mongoURI builds the connection string the tools connect with. Users
+// authenticate against the database itself unless authSource says otherwise,
+// and single hosts are connected to directly so replica set discovery does not
+// bypass an SSH tunnel.
+func mongoURI(conn *dbConnection) string {
+	u := url.URL{
+		Scheme: "mongodb",
+		Host:   net.JoinHostPort(conn.remoteHost(), conn.port),
+		Path:   "/",
+	}
+	if conn.user != "" {
+		u.User = url.UserPassword(conn.user, conn.password)
+	}
+
+	query := url.Values{}
+	for key, value := range conn.params {
+		query.Set(key, value)
+	}
+	if conn.user != "" && query.Get("authSource") == "" {
+		query.Set("authSource", conn.dbName)
+	}
+	if query.Get("replicaSet") == "" && query.Get("directConnection") == "" {
+		query.Set("directConnection", "true")
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// writeMongoConfig writes the connection string to a private --config file so
+// the password does not show up in the process list, returning the file path
+func writeMongoConfig(conn *dbConnection) (string, error) {
+	data, err := yaml.Marshal(map[string]string{"uri": mongoURI(conn)})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode MongoDB tool config: %w", err)
+	}
+
+	file, err := os.CreateTemp("", "omti-mongo-*.yaml")
+	if err != nil {
+		return "", fmt.Errorf("failed to create MongoDB tool config: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write MongoDB tool config: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// mongoDump is the output of mongodump, whose config file is removed once the dump is done
+type mongoDump struct {
+	io.ReadCloser
+	configFile string
+}
+
+func (d *mongoDump) Close() error {
+	defer os.Remove(d.configFile)
+	return d.ReadCloser.Close()
+}
+
+// checkDump rejects dump formats and object filters, which have no mongodump equivalent yet
+func (mongoEngine) checkDump(opts dumpOptions) error {
+	switch {
+	case opts.format != "" || opts.jobs > 1:
+		return fmt.Errorf("--format and --jobs are only supported for PostgreSQL backups")
+	case !opts.filters.empty():
+		return fmt.Errorf("object filters are not supported for MongoDB backups")
+	}
+	return nil
+}
+
+// dump runs mongodump into an archive on stdout, gzip-compressed unless the
+// backup pipeline compresses it
+func (mongoEngine) dump(ctx context.Context, conn *dbConnection, opts dumpOptions) (io.ReadCloser, error) {
+	configFile, err := writeMongoConfig(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{
+		"--config=" + configFile,
+		"--db=" + conn.dbName,
+		"--archive",
+	}
+	if !opts.compressed {
+		args = append(args, "--gzip")
+	}
+	dump, err := startDumpCommand(ctx, conn, nil, "mongodump", args...)
+	if err != nil {
+		os.Remove(configFile)
+		return nil, err
+	}
+	return &mongoDump{ReadCloser: dump, configFile: configFile}, nil
+}
+
+// describeDump reads the versions from the header document of the archive,
+// unpacking the start of archives mongodump gzipped
+func (mongoEngine) describeDump(ctx context.Context, head []byte, manifest *backupManifest) {
+	manifest.Format = "archive"
+	if bytes.HasPrefix(head, compressionCodecs["gzip"].magic) {
+		manifest.Format = "archive+gzip"
+		zr, err := gzip.NewReader(bytes.NewReader(head))
+		if err != nil {
+			return
+		}
+		// The head is cut off mid-stream, keep whatever unpacks
+		head, _ = io.ReadAll(zr)
+	}
+	if !bytes.HasPrefix(head, mongoArchiveMagic) {
+		return
+	}
+	header := parseBSONStrings(head[len(mongoArchiveMagic):])
+	manifest.ServerVersion = header["server_version"]
+	manifest.DumpVersion = header["tool_version"]
+}
+
+// unpackMongoArchive returns a reader over the plain archive, removing the gzip
+// layer of archives mongodump compressed. Reading the backup already removes it
+// when it is the outermost one, so it may or may not still be there.
+func unpackMongoArchive(archive io.Reader) (*bufio.Reader, error) {
+	buffered := bufio.NewReader(archive)
+	if head, err := buffered.Peek(len(compressionCodecs["gzip"].magic)); err == nil && bytes.Equal(head, compressionCodecs["gzip"].magic) {
+		zr, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unpack archive: %w", err)
+		}
+		return bufio.NewReaderSize(zr, mongoPreludeSize), nil
+	}
+	return bufio.NewReaderSize(buffered, mongoPreludeSize), nil
+}
+
+// mongoArchiveDatabase returns the database of the first collection described in
+// the prelude at the start of a plain archive, after the header document, or ""
+// when the archive holds no collections
+func mongoArchiveDatabase(head []byte) string {
+	if !bytes.HasPrefix(head, mongoArchiveMagic) {
+		return ""
+	}
+	prelude := head[len(mongoArchiveMagic):]
+	if len(prelude) < 4 {
+		return ""
+	}
+	headerSize := int64(binary.LittleEndian.Uint32(prelude))
+	if headerSize < 5 || headerSize+4 > int64(len(prelude)) {
+		return ""
+	}
+	// The prelude ends with a length of -1 after the last collection
+	collection := prelude[headerSize:]
+	if int32(binary.LittleEndian.Uint32(collection)) < 5 {
+		return ""
+	}
+	return parseBSONStrings(collection)["db"]
+}
+
+// verifyArchive checks the archive header and reads the archive to its end
+func (mongoEngine) verifyArchive(ctx context.Context, archive io.Reader) (string, error) {
+	plain, err := unpackMongoArchive(archive)
+	if err != nil {
+		return "", err
+	}
+	head, _ := plain.Peek(mongoPreludeSize)
+	if !bytes.HasPrefix(head, mongoArchiveMagic) {
+		return "", fmt.Errorf("not a mongodump archive")
+	}
+	database := mongoArchiveDatabase(head)
+
+	size, err := io.Copy(io.Discard, plain)
+	if err != nil {
+		return "", fmt.Errorf("failed to read archive: %w", err)
+	}
+	if database == "" {
+		return fmt.Sprintf("empty mongodump archive of %s", formatBytes(size)), nil
+	}
+	return fmt.Sprintf("mongodump archive of %s, %s", database, formatBytes(size)), nil
+}
+
+// parseBSONStrings returns the string fields of a BSON document, stopping at the
+// first field of a type it does not know how to skip
+func parseBSONStrings(doc []byte) map[string]string {
+	fields := map[string]string{}
+	if len(doc) < 4 {
+		return fields
+	}
+	pos := 4
+
+	for pos < len(doc) && doc[pos] != 0 {
+		kind := doc[pos]
+		end := bytes.IndexByte(doc[pos+1:], 0)
+		if end < 0 {
+			return fields
+		}
+		name := string(doc[pos+1 : pos+1+end])
+		pos += end + 2
+
+		switch kind {
+		case 0x02: // string
+			if pos+4 > len(doc) {
+				return fields
+			}
+			length := int(binary.LittleEndian.Uint32(doc[pos:]))
+			if length < 1 || pos+4+length > len(doc) {
+				return fields
+			}
+			fields[name] = string(doc[pos+4 : pos+4+length-1])
+			pos += 4 + length
+		case 0x08: // boolean
+			pos++
+		case 0x10: // int32
+			pos += 4
+		case 0x12: // int64
+			pos += 8
+		default:
+			return fields
+		}
+	}
+	return fields
+}
+
+// checkRestore rejects the PostgreSQL-only --schema option
+func (mongoEngine) checkRestore(opts restoreOptions) error {
+	if opts.schema != "" {
+		return fmt.Errorf("--schema is not supported for MongoDB restores")
+	}
+	return nil
+}
+
+// createDatabase has nothing to do, MongoDB creates databases on first write
+func (mongoEngine) createDatabase(ctx context.Context, conn *dbConnection, dropExisting bool) error {
+	return nil
+}
+
+// restore streams the plain archive into mongorestore. When the backup was taken
+// from a different database, as named in the archive's prelude, its collections
+// are renamed into the target database; --table restricts the restore to a
+// single collection.
+func (mongoEngine) restore(ctx context.Context, conn *dbConnection, backupFile string, archive io.Reader, info backupArchive, opts restoreOptions) error {
+	plain, err := unpackMongoArchive(archive)
+	if err != nil {
+		return err
+	}
+	head, _ := plain.Peek(mongoPreludeSize)
+	if !bytes.HasPrefix(head, mongoArchiveMagic) {
+		return fmt.Errorf("not a mongodump archive")
+	}
+	sourceDB := mongoArchiveDatabase(head)
+	if sourceDB == "" {
+		// Nothing to rename in an archive without collections
+		sourceDB = conn.dbName
+	}
+
+	configFile, err := writeMongoConfig(conn)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(configFile)
+
+	args := []string{
+		"--config=" + configFile,
+		"--archive",
+		"--numParallelCollections=" + strconv.Itoa(opts.jobs),
+	}
+	if opts.table != "" {
+		args = append(args, fmt.Sprintf("--nsInclude=%s.%s", sourceDB, opts.table))
+	} else {
+		args = append(args, fmt.Sprintf("--nsInclude=%s.*", sourceDB))
+	}
+	if sourceDB != conn.dbName {
+		args = append(args,
+			fmt.Sprintf("--nsFrom=%s.*", sourceDB),
+			fmt.Sprintf("--nsTo=%s.*", conn.dbName),
+		)
+	}
+	if opts.clean {
+		args = append(args, "--drop")
+	}
+
+	fmt.Printf("📦 Streaming %s into mongorestore\n", info)
+	return runDBToolWithInput(ctx, "mongorestore", conn, plain, args...)
+}
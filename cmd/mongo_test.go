@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// bsonDoc encodes string fields as a BSON document
+func bsonDoc(fields ...string) []byte {
+	var body []byte
+	for i := 0; i+1 < len(fields); i += 2 {
+		body = append(body, 0x02)
+		body = append(body, fields[i]...)
+		body = append(body, 0)
+		body = binary.LittleEndian.AppendUint32(body, uint32(len(fields[i+1])+1))
+		body = append(body, fields[i+1]...)
+		body = append(body, 0)
+	}
+	doc := binary.LittleEndian.AppendUint32(nil, uint32(len(body)+5))
+	return append(append(doc, body...), 0)
+}
+
+// mongoArchive builds the start of a mongodump archive holding collections of db
+func mongoArchive(db string, collections ...string) []byte {
+	archive := append([]byte{}, mongoArchiveMagic...)
+	archive = append(archive, bsonDoc("version", "0.1", "server_version", "7.0.5", "tool_version", "100.9.4")...)
+	for _, collection := range collections {
+		archive = append(archive, bsonDoc("db", db, "collection", collection, "metadata", `{"indexes":[]}`)...)
+	}
+	archive = append(archive, 0xff, 0xff, 0xff, 0xff)
+	return append(archive, "collection data"...)
+}
+
+func TestMongoArchiveDatabase(t *testing.T) {
+	full := mongoArchive("orders", "items", "customers")
+	// The header ends where an archive without collections has its terminator
+	headerEnd := len(mongoArchive("orders")) - 4 - len("collection data")
+	// The first collection's length is followed by its "db" string field
+	dbFieldEnd := headerEnd + 4 + len("\x02db\x00") + 4 + len("orders\x00")
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{name: "collections", head: full, want: "orders"},
+		{name: "no collections", head: mongoArchive("orders")},
+		{name: "cut off in the header", head: full[:20]},
+		{name: "cut off after the database of the first collection", head: full[:dbFieldEnd], want: "orders"},
+		{name: "cut off before the database of the first collection", head: full[:dbFieldEnd-1]},
+		{name: "not an archive", head: []byte("PGDMP")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mongoArchiveDatabase(tt.head); got != tt.want {
+				t.Errorf("mongoArchiveDatabase = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMongoDescribeAndVerify(t *testing.T) {
+	plain := mongoArchive("orders", "items")
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	zw.Write(plain)
+	zw.Close()
+
+	tests := []struct {
+		name       string
+		archive    []byte
+		wantFormat string
+	}{
+		{name: "plain", archive: plain, wantFormat: "archive"},
+		{name: "gzipped by mongodump", archive: gzipped.Bytes(), wantFormat: "archive+gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := &backupManifest{}
+			mongoEngine{}.describeDump(context.Background(), tt.archive, manifest)
+			if manifest.Format != tt.wantFormat || manifest.ServerVersion != "7.0.5" || manifest.DumpVersion != "100.9.4" {
+				t.Errorf("describeDump = %q, %q, %q, want %q, 7.0.5, 100.9.4", manifest.Format, manifest.ServerVersion, manifest.DumpVersion, tt.wantFormat)
+			}
+
+			description, err := mongoEngine{}.verifyArchive(context.Background(), bytes.NewReader(tt.archive))
+			if err != nil {
+				t.Fatalf("verifyArchive failed: %v", err)
+			}
+			if want := "mongodump archive of orders, " + formatBytes(int64(len(plain))); description != want {
+				t.Errorf("verifyArchive = %q, want %q", description, want)
+			}
+
+			unpacked, err := unpackMongoArchive(bytes.NewReader(tt.archive))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := io.ReadAll(unpacked); !bytes.Equal(got, plain) {
+				t.Errorf("unpackMongoArchive did not return the plain archive")
+			}
+		})
+	}
+	if _, err := (mongoEngine{}).verifyArchive(context.Background(), bytes.NewReader([]byte("PGDMP"))); err == nil {
+		t.Errorf("verifyArchive accepted a pg_dump archive")
+	}
+}
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// mysqlEngine backs up MySQL and MariaDB databases with mysqldump and restores them with mysql
+type mysqlEngine struct{}
+
+// mysqlParams lists the connection parameters passed on to the MySQL client
+// tools as --<name>=<value> options
+var mysqlParams = map[string]bool{
+	"ssl":                    true,
+	"ssl-mode":               true,
+	"ssl-ca":                 true,
+	"ssl-capath":             true,
+	"ssl-cert":               true,
+	"ssl-key":                true,
+	"ssl-cipher":             true,
+	"ssl-verify-server-cert": true,
+	"tls-version":            true,
+	"default-character-set":  true,
+	"protocol":               true,
+	"compress":               true,
+}
+
+var (
+	mysqlDumpVersionPattern   = regexp.MustCompile(`Distrib ([^,\s]+)|Ver ([^,\s]+)`)
+	mysqlServerVersionPattern = regexp.MustCompile(`(?m)^-- Server version\s+(\S+)`)
+)
+
+func (mysqlEngine) name() string                 { return "mysql" }
+func (mysqlEngine) defaultPort() string          { return "3306" }
+func (mysqlEngine) dumpExt(format string) string { return ".sql" }
+
+// checkParam accepts the options in mysqlParams, spelled with dashes or underscores
+func (mysqlEngine) checkParam(key string) error {
+	if !mysqlParams[mysqlOptionName(key)] {
+		return fmt.Errorf("unsupported connection parameter %q", key)
+	}
+	return nil
+}
+
+// env passes the password in MYSQL_PWD so it does not show up in the process list
+func (mysqlEngine) env(conn *dbConnection) ([]string, func(), error) {
+	env := os.Environ()
+	if conn.password != "" {
+		env = append(env, fmt.Sprintf("MYSQL_PWD=%s", conn.password))
+	}
+	return env, func() {}, nil
+}
+
+// mysqlOptionName normalises a parameter name to the option spelling, e.g. ssl_mode to ssl-mode
+func mysqlOptionName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// mysqlArgs returns the connection options shared by the MySQL client tools
+func mysqlArgs(conn *dbConnection) []string {
+	var args []string
+	if conn.host != "" {
+		args = append(args, "-h", conn.host)
+	}
+	args = append(args, "-P", conn.port)
+	if conn.user != "" {
+		args = append(args, "-u", conn.user)
+	}
+
+	keys := make([]string, 0, len(conn.params))
+	for key := range conn.params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, fmt.Sprintf("--%s=%s", mysqlOptionName(key), conn.params[key]))
+	}
+	return args
+}
+
+// quoteMySQLIdentifier quotes a database name for use in SQL statements
+func quoteMySQLIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// checkDump rejects dump formats and the filters mysqldump has no equivalent
+// for. MySQL has no schemas within a database, and tables are named exactly,
+// without patterns.
+func (mysqlEngine) checkDump(opts dumpOptions) error {
+	filters := opts.filters
+	switch {
+	case opts.format != "" || opts.jobs > 1:
+		return fmt.Errorf("--format and --jobs are only supported for PostgreSQL backups")
+	case len(filters.Schemas) > 0 || len(filters.ExcludeSchemas) > 0:
+		return fmt.Errorf("--schema and --exclude-schema are not supported for MySQL backups")
+	case len(filters.ExcludeTableData) > 0:
+		return fmt.Errorf("--exclude-table-data is not supported for MySQL backups")
+	}
+	return nil
+}
+
+// dump runs mysqldump in a single consistent transaction. The dump does not
+// name the database, so it can be restored under a different name.
+func (mysqlEngine) dump(ctx context.Context, conn *dbConnection, opts dumpOptions) (io.ReadCloser, error) {
+	args := append(mysqlArgs(conn),
+		"--single-transaction",
+		"--quick",
+		"--routines",
+		"--triggers",
+		"--events",
+		"--hex-blob",
+	)
+	args = append(args, mysqlDumpFilterArgs(conn.dbName, opts.filters)...)
+	args = append(args, conn.dbName)
+	args = append(args, opts.filters.Tables...)
+	return startDumpCommand(ctx, conn, nil, "mysqldump", args...)
+}
+
+// mysqlDumpFilterArgs returns the mysqldump options for the object filters of a
+// dump of dbName. The tables to dump are not options, they follow the database name.
+func mysqlDumpFilterArgs(dbName string, filters dumpFilters) []string {
+	var args []string
+	if filters.SchemaOnly {
+		args = append(args, "--no-data")
+	}
+	if filters.DataOnly {
+		args = append(args, "--no-create-info")
+	}
+	for _, table := range filters.ExcludeTables {
+		args = append(args, fmt.Sprintf("--ignore-table=%s.%s", dbName, table))
+	}
+	return args
+}
+
+// describeDump reads the versions from the comment block at the top of the dump
+func (mysqlEngine) describeDump(ctx context.Context, head []byte, manifest *backupManifest) {
+	manifest.Format = "sql"
+	if match := mysqlServerVersionPattern.FindSubmatch(head); match != nil {
+		manifest.ServerVersion = string(match[1])
+	}
+
+	version := head
+	if firstLine, _, _ := strings.Cut(string(head), "\n"); !strings.Contains(firstLine, " dump ") {
+		version, _ = commandContext(ctx, "mysqldump", "--version").Output()
+	}
+	if match := mysqlDumpVersionPattern.FindSubmatch(version); match != nil {
+		manifest.DumpVersion = string(match[1]) + string(match[2])
+	}
+}
+
+// checkRestore rejects the pg_restore-only options
+func (mysqlEngine) checkRestore(opts restoreOptions) error {
+	switch {
+	case opts.jobs > 1:
+		return fmt.Errorf("--jobs is not supported for MySQL restores")
+	case opts.schema != "":
+		return fmt.Errorf("--schema is not supported for MySQL restores")
+	case opts.table != "":
+		return fmt.Errorf("--table is not supported for MySQL restores")
+	}
+	return nil
+}
+
+// createDatabase creates the target database with CREATE DATABASE, dropping an existing one first
+func (e mysqlEngine) createDatabase(ctx context.Context, conn *dbConnection, dropExisting bool) error {
+	if dropExisting {
+		if err := e.dropDatabase(ctx, conn); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Dropped existing database %s\n", conn.dbName)
+	}
+
+	createArgs := append(mysqlArgs(conn), "-e", "CREATE DATABASE "+quoteMySQLIdentifier(conn.dbName))
+	if err := runDBTool(ctx, "mysql", conn, createArgs...); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Created database %s\n", conn.dbName)
+	return nil
+}
+
+// restore streams the SQL dump into the mysql client. mysqldump already drops
+// every table before recreating it, so --clean needs no extra work.
+func (mysqlEngine) restore(ctx context.Context, conn *dbConnection, backupFile string, archive io.Reader, info backupArchive, opts restoreOptions) error {
+	fmt.Printf("📦 Streaming %s into mysql\n", info)
+	args := append(mysqlArgs(conn), conn.dbName)
+	return runDBToolWithInput(ctx, "mysql", conn, archive, args...)
+}
+
+// dropDatabase drops the database with DROP DATABASE
+func (mysqlEngine) dropDatabase(ctx context.Context, conn *dbConnection) error {
+	dropArgs := append(mysqlArgs(conn), "-e", "DROP DATABASE IF EXISTS "+quoteMySQLIdentifier(conn.dbName))
+	return runDBTool(ctx, "mysql", conn, dropArgs...)
+}
+
+// mysqlDumpTrailer ends every dump that mysqldump finished writing
+var mysqlDumpTrailer = []byte("-- Dump completed")
+
+// verifyArchive checks that the dump ends with the trailer mysqldump writes on success
+func (mysqlEngine) verifyArchive(ctx context.Context, archive io.Reader) (string, error) {
+	tail, size, err := readTail(archive, 4096)
+	if err != nil {
+		return "", fmt.Errorf("failed to read dump: %w", err)
+	}
+
+	if !bytes.Contains(tail, mysqlDumpTrailer) {
+		return "", fmt.Errorf("dump does not end with %q, it may be truncated", mysqlDumpTrailer)
+	}
+	return fmt.Sprintf("complete SQL dump of %s", formatBytes(size)), nil
+}
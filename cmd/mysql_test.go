@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMySQLDumpFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters dumpFilters
+		want    []string
+		wantErr string
+	}{
+		{name: "no filters"},
+		{name: "schema only", filters: dumpFilters{SchemaOnly: true}, want: []string{"--no-data"}},
+		{name: "data only", filters: dumpFilters{DataOnly: true}, want: []string{"--no-create-info"}},
+		{
+			name:    "excluded tables are qualified with the database",
+			filters: dumpFilters{ExcludeTables: []string{"sessions", "cache"}},
+			want:    []string{"--ignore-table=shop.sessions", "--ignore-table=shop.cache"},
+		},
+		{name: "tables are arguments, not options", filters: dumpFilters{Tables: []string{"orders"}}},
+		{name: "schemas", filters: dumpFilters{Schemas: []string{"public"}}, wantErr: "--schema and --exclude-schema are not supported for MySQL backups"},
+		{name: "excluded schemas", filters: dumpFilters{ExcludeSchemas: []string{"audit"}}, wantErr: "--schema and --exclude-schema are not supported for MySQL backups"},
+		{name: "excluded table data", filters: dumpFilters{ExcludeTableData: []string{"events"}}, wantErr: "--exclude-table-data is not supported for MySQL backups"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := mysqlEngine{}.checkDump(dumpOptions{filters: tt.filters})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("checkDump error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkDump failed: %v", err)
+			}
+			if got := mysqlDumpFilterArgs("shop", tt.filters); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mysqlDumpFilterArgs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// partialMarker separates the final name of a backup from the random suffix of
+// the temporary file or directory it is written to, e.g.
+// golang_backup_20240101_030000.dump.partial-123456
+const partialMarker = ".partial-"
+
+// partialBackupName returns the final name of a temporary backup file, and
+// whether name is one
+func partialBackupName(name string) (string, bool) {
+	i := strings.LastIndex(name, partialMarker)
+	if i <= 0 {
+		return "", false
+	}
+	return name[:i], true
+}
+
+// createPartialFile creates the temporary file a backup is written to before it
+// is renamed to backupFile. It lives in the same directory, so the rename is atomic.
+func createPartialFile(backupFile string) (*os.File, error) {
+	file, err := os.CreateTemp(filepath.Dir(backupFile), filepath.Base(backupFile)+partialMarker+"*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup file: %w", err)
+	}
+	// CreateTemp makes the file private, backups keep the permissions they always had
+	if err := file.Chmod(0o644); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to create backup file: %w", err)
+	}
+	return file, nil
+}
+
+// createPartialDir creates the empty temporary directory a directory dump is
+// written to before it is renamed to backupFile
+func createPartialDir(backupFile string) (string, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(backupFile), filepath.Base(backupFile)+partialMarker+"*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	return dir, nil
+}
+
+// commitPartialFile checks that the synced temporary file holds exactly the
+// bytes the pipeline wrote, then renames it to backupFile. The temporary file
+// is removed when it cannot be committed.
+func commitPartialFile(ctx context.Context, partial, backupFile string, result *pipelineResult) error {
+	err := verifyPartialFile(ctx, partial, result)
+	if err == nil {
+		err = commitPartial(partial, backupFile)
+	}
+	if err != nil {
+		os.Remove(partial)
+	}
+	return err
+}
+
+// verifyPartialFile compares the checksum of the temporary file with the data the pipeline wrote
+func verifyPartialFile(ctx context.Context, partial string, result *pipelineResult) error {
+	sum, err := backupChecksum(ctx, partial)
+	if err != nil {
+		return fmt.Errorf("failed to verify backup file: %w", err)
+	}
+	if sum != result.checksum {
+		return fmt.Errorf("backup file %s does not match the data written to it: sha256 is %s, expected %s", partial, sum, result.checksum)
+	}
+	return nil
+}
+
+// commitPartial moves a verified temporary backup to its final name, never
+// replacing an existing backup, and syncs the directory, so the move itself
+// survives a crash
+func commitPartial(partial, backupFile string) error {
+	if err := renameNoReplace(partial, backupFile); err != nil {
+		return fmt.Errorf("failed to move backup into place: %w", err)
+	}
+	return syncDir(filepath.Dir(backupFile))
+}
+
+// renameNoReplace renames from to to unless to already exists. A file is
+// linked under the new name first, which fails atomically when the name is
+// taken; directories and file systems without hard links are checked first.
+func renameNoReplace(from, to string) error {
+	err := os.Link(from, to)
+	if err == nil {
+		return os.Remove(from)
+	}
+	if os.IsExist(err) {
+		return fmt.Errorf("%s already exists", to)
+	}
+	if _, err := os.Lstat(to); err == nil {
+		return fmt.Errorf("%s already exists", to)
+	}
+	return os.Rename(from, to)
+}
+
+// writeFileAtomic writes data to path through a synced temporary file, so
+// readers see either the old file or the complete new one
+func writeFileAtomic(path string, data []byte) error {
+	file, err := createPartialFile(path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir flushes a directory's entries to disk
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to sync %s: %w", dir, err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", dir, err)
+	}
+	return nil
+}
+
+// syncTree flushes every file and directory of a directory dump to disk
+func syncTree(root string) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return syncDir(path)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to sync %s: %w", path, err)
+		}
+		defer file.Close()
+		if err := file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync %s: %w", path, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPartialBackupName(t *testing.T) {
+	tests := []struct {
+		name        string
+		want        string
+		wantPartial bool
+	}{
+		{name: "golang_backup_20240101_030000.dump.partial-123456", want: "golang_backup_20240101_030000.dump", wantPartial: true},
+		{name: "golang_backup_20240101_030000.dump.json.partial-1", want: "golang_backup_20240101_030000.dump.json", wantPartial: true},
+		{name: "a.partial-1.partial-2", want: "a.partial-1", wantPartial: true},
+		{name: "golang_backup_20240101_030000.dump"},
+		{name: ".partial-123"},
+	}
+	for _, tt := range tests {
+		got, partial := partialBackupName(tt.name)
+		if got != tt.want || partial != tt.wantPartial {
+			t.Errorf("partialBackupName(%q) = %q, %v, want %q, %v", tt.name, got, partial, tt.want, tt.wantPartial)
+		}
+	}
+}
+
+// readFileString returns the contents of a file, failing the test if it cannot be read
+func readFileString(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRenameNoReplace(t *testing.T) {
+	t.Run("file", func(t *testing.T) {
+		dir := t.TempDir()
+		from, to := filepath.Join(dir, "from"), filepath.Join(dir, "to")
+		os.WriteFile(from, []byte("new"), 0644)
+		if err := renameNoReplace(from, to); err != nil {
+			t.Fatalf("renameNoReplace failed: %v", err)
+		}
+		if got := dirFileNames(t, dir); !reflect.DeepEqual(got, []string{"to"}) {
+			t.Errorf("renameNoReplace left %v, want [to]", got)
+		}
+	})
+	t.Run("target exists", func(t *testing.T) {
+		dir := t.TempDir()
+		from, to := filepath.Join(dir, "from"), filepath.Join(dir, "to")
+		os.WriteFile(from, []byte("new"), 0644)
+		os.WriteFile(to, []byte("old"), 0644)
+		if err := renameNoReplace(from, to); err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Fatalf("renameNoReplace error = %v, want already exists", err)
+		}
+		if readFileString(t, from) != "new" || readFileString(t, to) != "old" {
+			t.Errorf("renameNoReplace changed the files")
+		}
+	})
+	t.Run("directory", func(t *testing.T) {
+		dir := t.TempDir()
+		from, to := filepath.Join(dir, "from"), filepath.Join(dir, "to")
+		os.Mkdir(from, 0755)
+		os.WriteFile(filepath.Join(from, "toc.dat"), []byte("PGDMP"), 0644)
+		if err := renameNoReplace(from, to); err != nil {
+			t.Fatalf("renameNoReplace failed: %v", err)
+		}
+		if readFileString(t, filepath.Join(to, "toc.dat")) != "PGDMP" {
+			t.Errorf("renameNoReplace did not move the directory")
+		}
+	})
+	t.Run("directory target exists", func(t *testing.T) {
+		dir := t.TempDir()
+		from, to := filepath.Join(dir, "from"), filepath.Join(dir, "to")
+		os.Mkdir(from, 0755)
+		os.Mkdir(to, 0755)
+		if err := renameNoReplace(from, to); err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Fatalf("renameNoReplace error = %v, want already exists", err)
+		}
+		if got := dirFileNames(t, dir); !reflect.DeepEqual(got, []string{"from", "to"}) {
+			t.Errorf("renameNoReplace left %v, want [from to]", got)
+		}
+	})
+}
+
+func TestCommitPartialFile(t *testing.T) {
+	const backupName = "golang_backup_20240101_030000.dump"
+
+	tests := []struct {
+		name     string
+		existing string
+		checksum string
+		wantErr  string
+		want     []string
+	}{
+		{name: "committed", checksum: checksumOf("dump"), want: []string{backupName}},
+		{name: "target exists", existing: "older dump", checksum: checksumOf("dump"), wantErr: "already exists", want: []string{backupName}},
+		{name: "checksum mismatch leaves no file", checksum: checksumOf("other dump"), wantErr: "does not match the data written to it"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			backupFile := filepath.Join(dir, backupName)
+			if tt.existing != "" {
+				os.WriteFile(backupFile, []byte(tt.existing), 0644)
+			}
+			file, err := createPartialFile(backupFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file.WriteString("dump")
+			file.Close()
+
+			err = commitPartialFile(context.Background(), file.Name(), backupFile, &pipelineResult{checksum: tt.checksum})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("commitPartialFile error = %v, want it to contain %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("commitPartialFile failed: %v", err)
+			}
+
+			if got := dirFileNames(t, dir); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("commitPartialFile left %v, want %v", got, tt.want)
+			}
+			if tt.existing != "" && readFileString(t, backupFile) != tt.existing {
+				t.Errorf("commitPartialFile replaced the existing backup")
+			}
+			if tt.wantErr == "" && readFileString(t, backupFile) != "dump" {
+				t.Errorf("committed backup does not hold the dump")
+			}
+		})
+	}
+}
+
+func TestStreamDumpCleansUp(t *testing.T) {
+	const backupName = "golang_backup_20240101_030000.dump"
+	pipeline, err := newBackupPipeline("none", 0, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump := func(ctx context.Context, conn *dbConnection, opts dumpOptions) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("dump")), nil
+	}
+
+	tests := []struct {
+		name      string
+		existing  string
+		startDump func(ctx context.Context, conn *dbConnection, opts dumpOptions) (io.ReadCloser, error)
+		wantErr   string
+		want      []string
+	}{
+		{name: "success", startDump: dump, want: []string{backupName}},
+		{name: "target exists", existing: "older dump", startDump: dump, wantErr: "already exists", want: []string{backupName}},
+		{
+			name: "dump fails to start",
+			startDump: func(ctx context.Context, conn *dbConnection, opts dumpOptions) (io.ReadCloser, error) {
+				return nil, errors.New("pg_dump not found")
+			},
+			wantErr: "pg_dump not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			backupFile := filepath.Join(dir, backupName)
+			if tt.existing != "" {
+				os.WriteFile(backupFile, []byte(tt.existing), 0644)
+			}
+
+			_, err := streamDump(context.Background(), &dbConnection{}, backupFile, pipeline, dumpOptions{}, tt.startDump)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("streamDump error = %v, want it to contain %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("streamDump failed: %v", err)
+			}
+
+			if got := dirFileNames(t, dir); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("streamDump left %v, want %v", got, tt.want)
+			}
+			want := "dump"
+			if tt.existing != "" {
+				want = tt.existing
+			}
+			if len(tt.want) > 0 && readFileString(t, backupFile) != want {
+				t.Errorf("%s holds %q, want %q", backupName, readFileString(t, backupFile), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// passwordSource says where the password left out of a db_config comes from
+type passwordSource struct {
+	// File holds the password on its first line
+	File string `yaml:"password_file"`
+	// Env names the environment variable holding the password
+	Env string `yaml:"password_env"`
+	// Secret is a secret reference such as secret://vault/db/golang#password
+	Secret string `yaml:"password_secret"`
+
+	// prompt asks for the password on the terminal when the command starts
+	prompt bool
+	// prompted holds the password entered at the prompt
+	prompted string
+}
+
+// passwordFlags holds the password flags of the command being run
+var passwordFlags passwordSource
+
+// addPasswordFlags registers the flags that keep the database password out of db_config
+func addPasswordFlags(cmd *cobra.Command, source *passwordSource) {
+	cmd.Flags().StringVar(&source.File, "password-file", "", "Read the database password from the first line of this file instead of db_config")
+	cmd.Flags().StringVar(&source.Env, "password-env", "", "Read the database password from this environment variable instead of db_config")
+	cmd.Flags().StringVar(&source.Secret, "password-secret", "", "Read the database password from a secret reference such as secret://vault/<mount>/<path>#<key> instead of db_config")
+	cmd.Flags().BoolVar(&source.prompt, "password-prompt", false, "Prompt for the database password without echoing it")
+}
+
+// names returns the flags of the sources that are set
+func (s *passwordSource) names() []string {
+	var names []string
+	if s.File != "" {
+		names = append(names, "--password-file")
+	}
+	if s.Env != "" {
+		names = append(names, "--password-env")
+	}
+	if s.Secret != "" {
+		names = append(names, "--password-secret")
+	}
+	if s.prompt {
+		names = append(names, "--password-prompt")
+	}
+	return names
+}
+
+// readPrompt asks for the password of dbConfig once, before any work is done,
+// so scheduled runs of the same job do not prompt again
+func (s *passwordSource) readPrompt(dbConfig string) error {
+	if !s.prompt || s.prompted != "" {
+		return nil
+	}
+	if names := s.names(); len(names) > 1 {
+		return fmt.Errorf("%s cannot be combined", strings.Join(names, " and "))
+	}
+	label := "the database"
+	if conn, err := parseDBConfig(dbConfig); err == nil {
+		label = conn.String()
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return fmt.Errorf("--password-prompt needs a terminal on stdin")
+	}
+
+	fmt.Fprintf(os.Stderr, "🔑 Password for %s: ", label)
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return fmt.Errorf("failed to read password: %w", err)
+	}
+	if len(password) == 0 {
+		return fmt.Errorf("no password entered")
+	}
+	s.prompted = string(password)
+	return nil
+}
+
+// apply sets the password of conn from the source. Without a source,
+// PostgreSQL passwords missing from db_config are looked up in ~/.pgpass, as
+// libpq would, but for the configured host rather than the end of an SSH tunnel.
+func (s *passwordSource) apply(ctx context.Context, conn *dbConnection) error {
+	names := s.names()
+	switch {
+	case len(names) > 1:
+		return fmt.Errorf("%s cannot be combined", strings.Join(names, " and "))
+	case len(names) == 0:
+		if conn.password == "" && conn.engine.name() == "postgres" {
+			password, err := lookupPgpass(conn)
+			if err != nil {
+				return err
+			}
+			conn.password = password
+		}
+		return nil
+	case conn.path != "":
+		return fmt.Errorf("%s databases have no password, %s does not apply", conn.engine.name(), names[0])
+	case conn.password != "":
+		return fmt.Errorf("db_config already contains a password, leave it out when using %s", names[0])
+	}
+
+	switch {
+	case s.File != "":
+		password, err := readPasswordFile(s.File)
+		if err != nil {
+			return err
+		}
+		conn.password = password
+	case s.Env != "":
+		password, ok := os.LookupEnv(s.Env)
+		if !ok || password == "" {
+			return fmt.Errorf("environment variable %s is not set", s.Env)
+		}
+		conn.password = password
+	case s.Secret != "":
+		if !isSecretRef(s.Secret) {
+			return fmt.Errorf("--password-secret %q is not a secret reference", s.Secret)
+		}
+		password, err := resolveSecret(ctx, s.Secret)
+		if err != nil {
+			return err
+		}
+		conn.password = password
+	default:
+		if s.prompted == "" {
+			return fmt.Errorf("--password-prompt is not supported here")
+		}
+		conn.password = s.prompted
+	}
+	return nil
+}
+
+// readPasswordFile reads a password from the first line of a file, warning
+// when other users can read the file
+func readPasswordFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password file: %w", err)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		fmt.Fprintf(os.Stderr, "⚠️ Password file %s is accessible by other users, restrict it with chmod 600\n", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password file: %w", err)
+	}
+	password, _, _ := strings.Cut(string(data), "\n")
+	password = strings.TrimSuffix(password, "\r")
+	if password == "" {
+		return "", fmt.Errorf("password file %s is empty", path)
+	}
+	return password, nil
+}
+
+// pgpassPath returns the PostgreSQL password file, $PGPASSFILE or ~/.pgpass
+func pgpassPath() string {
+	if path := os.Getenv("PGPASSFILE"); path != "" {
+		return path
+	}
+	return filepath.Join(os.Getenv("HOME"), ".pgpass")
+}
+
+// lookupPgpass returns the password of the first line of the PostgreSQL
+// password file matching the connection, or "" when none does. Like libpq, it
+// ignores a file that other users can read.
+func lookupPgpass(conn *dbConnection) (string, error) {
+	path := pgpassPath()
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read password file %s: %w", path, err)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		fmt.Fprintf(os.Stderr, "⚠️ Ignoring password file %s, it is accessible by other users (restrict it with chmod 600)\n", path)
+		return "", nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	user := conn.user
+	if user == "" {
+		user = os.Getenv("USER")
+	}
+	want := []string{conn.remoteHost(), conn.port, conn.dbName, user}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := splitPgpassLine(line)
+		if len(fields) != 5 {
+			continue
+		}
+		matched := true
+		for i, value := range want {
+			if fields[i] != "*" && fields[i] != value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return fields[4], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read password file %s: %w", path, err)
+	}
+	return "", nil
+}
+
+// splitPgpassLine splits a hostname:port:database:username:password line,
+// where \: and \\ stand for a literal colon and backslash
+func splitPgpassLine(line string) []string {
+	var fields []string
+	var field strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line):
+			i++
+			field.WriteByte(line[i])
+		case line[i] == ':' && len(fields) < 4:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(line[i])
+		}
+	}
+	return append(fields, field.String())
+}
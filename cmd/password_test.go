@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitPgpassLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{line: "localhost:5432:golang:postgres:secret", want: []string{"localhost", "5432", "golang", "postgres", "secret"}},
+		{line: "*:*:*:*:secret", want: []string{"*", "*", "*", "*", "secret"}},
+		{line: `db\:1:5432:my\:db:postgres:p\:w`, want: []string{"db:1", "5432", "my:db", "postgres", "p:w"}},
+		{line: `localhost:5432:golang:postgres:back\\slash\\`, want: []string{"localhost", "5432", "golang", "postgres", `back\slash\`}},
+		{line: `localhost:5432:golang:postgres:\*`, want: []string{"localhost", "5432", "golang", "postgres", "*"}},
+		{line: "localhost:5432:golang:postgres:a:b:c", want: []string{"localhost", "5432", "golang", "postgres", "a:b:c"}},
+		{line: "localhost:5432:golang:postgres:", want: []string{"localhost", "5432", "golang", "postgres", ""}},
+		{line: `localhost:5432:golang:postgres:trailing\`, want: []string{"localhost", "5432", "golang", "postgres", `trailing\`}},
+		{line: "localhost:5432:golang", want: []string{"localhost", "5432", "golang"}},
+	}
+	for _, tt := range tests {
+		if got := splitPgpassLine(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitPgpassLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestPgpassEscapeRoundTrip(t *testing.T) {
+	fields := []string{"db:1", "5432", `C:\data`, "*", `p\:w:\`}
+	var line string
+	for i, field := range fields {
+		if i > 0 {
+			line += ":"
+		}
+		line += pgpassEscape(field)
+	}
+	if got := splitPgpassLine(line); !reflect.DeepEqual(got, fields) {
+		t.Errorf("splitPgpassLine(%q) = %q, want %q", line, got, fields)
+	}
+}
+
+func TestLookupPgpass(t *testing.T) {
+	pgpass := "# comment\r\n" +
+		"\n" +
+		"too:few:fields\n" +
+		"db.example.com:5432:golang:postgres:exact\n" +
+		`db\:colon:5432:golang:postgres:escaped\:host` + "\n" +
+		"db.example.com:5433:*:postgres:any-database\n" +
+		"localhost:5432:golang:*:any-user\r\n" +
+		"*:*:shop:app:first\n" +
+		"*:*:shop:app:second\n" +
+		"*:*:*:fallback:wildcard\n"
+
+	tests := []struct {
+		name string
+		conn dbConnection
+		want string
+	}{
+		{name: "exact", conn: dbConnection{host: "db.example.com", port: "5432", dbName: "golang", user: "postgres"}, want: "exact"},
+		{name: "escaped colon", conn: dbConnection{host: "db:colon", port: "5432", dbName: "golang", user: "postgres"}, want: "escaped:host"},
+		{name: "wildcard database", conn: dbConnection{host: "db.example.com", port: "5433", dbName: "other", user: "postgres"}, want: "any-database"},
+		{name: "wildcard user with CRLF", conn: dbConnection{port: "5432", dbName: "golang", user: "someone"}, want: "any-user"},
+		{name: "empty host is localhost", conn: dbConnection{port: "5432", dbName: "golang", user: "postgres"}, want: "any-user"},
+		{name: "first match wins", conn: dbConnection{host: "anywhere", port: "1", dbName: "shop", user: "app"}, want: "first"},
+		{name: "user from the environment", conn: dbConnection{host: "anywhere", port: "1", dbName: "anything"}, want: "wildcard"},
+		{name: "no match", conn: dbConnection{host: "db.example.com", port: "5432", dbName: "other", user: "postgres"}},
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pgpass")
+	if err := os.WriteFile(path, []byte(pgpass), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PGPASSFILE", path)
+	t.Setenv("USER", "fallback")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lookupPgpass(&tt.conn)
+			if err != nil {
+				t.Fatalf("lookupPgpass failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("lookupPgpass(%s) = %q, want %q", &tt.conn, got, tt.want)
+			}
+		})
+	}
+
+	conn := &dbConnection{host: "db.example.com", port: "5432", dbName: "golang", user: "postgres"}
+	t.Run("file readable by others", func(t *testing.T) {
+		if err := os.Chmod(path, 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Chmod(path, 0600)
+		if got, err := lookupPgpass(conn); err != nil || got != "" {
+			t.Errorf("lookupPgpass = %q, %v, want the file ignored", got, err)
+		}
+	})
+	t.Run("missing file", func(t *testing.T) {
+		t.Setenv("PGPASSFILE", filepath.Join(dir, "missing"))
+		if got, err := lookupPgpass(conn); err != nil || got != "" {
+			t.Errorf("lookupPgpass = %q, %v, want no password", got, err)
+		}
+	})
+	t.Run("default file in the home directory", func(t *testing.T) {
+		home := t.TempDir()
+		if err := os.WriteFile(filepath.Join(home, ".pgpass"), []byte("*:*:*:*:from-home\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("PGPASSFILE", "")
+		t.Setenv("HOME", home)
+		if got, err := lookupPgpass(conn); err != nil || got != "from-home" {
+			t.Errorf("lookupPgpass = %q, %v, want %q", got, err, "from-home")
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	"filippo.io/age"
+)
+
+// backupPipeline describes how dump output is compressed and encrypted on its way to disk
+type backupPipeline struct {
+	codec      compressionCodec
+	level      int
+	recipients []age.Recipient
+	passphrase bool
+}
+
+// pipelineResult describes a backup file written by a backupPipeline
+type pipelineResult struct {
+	size     int64
+	checksum string
+	// header holds the first bytes of the unprocessed dump output
+	header []byte
+}
+
+// newBackupPipeline validates the compression and encryption settings of a backup
+func newBackupPipeline(compress string, level int, encryptTo []string, passphraseFile string) (*backupPipeline, error) {
+	codec, err := lookupCodec(compress, level)
+	if err != nil {
+		return nil, err
+	}
+	recipients, err := encryptionRecipients(encryptTo, passphraseFile)
+	if err != nil {
+		return nil, err
+	}
+	return &backupPipeline{
+		codec:      codec,
+		level:      level,
+		recipients: recipients,
+		passphrase: passphraseFile != "",
+	}, nil
+}
+
+// ext returns the file name extension added by the pipeline, e.g. ".gz.age"
+func (p *backupPipeline) ext() string {
+	if len(p.recipients) > 0 {
+		return p.codec.ext + encryptedExt
+	}
+	return p.codec.ext
+}
+
+// encryption names the encryption applied by the pipeline for the manifest
+func (p *backupPipeline) encryption() string {
+	switch {
+	case p.passphrase:
+		return "age-passphrase"
+	case len(p.recipients) > 0:
+		return "age-recipient"
+	default:
+		return "none"
+	}
+}
+
+// write streams src through compression and encryption into file, syncs it to
+// disk and closes it. Only the final bytes reach the disk, so an encrypted
+// backup never exists in plain text.
+func (p *backupPipeline) write(src io.Reader, file *os.File) (*pipelineResult, error) {
+	defer file.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	var sink io.Writer = io.MultiWriter(file, hash, counter)
+
+	var encryptor io.WriteCloser = nopWriteCloser{sink}
+	if len(p.recipients) > 0 {
+		var err error
+		encryptor, err = age.Encrypt(sink, p.recipients...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start encryption: %w", err)
+		}
+	}
+
+	compressor, err := p.codec.newWriter(encryptor, p.level)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s compression: %w", p.codec.name, err)
+	}
+
+	header := &headerCapture{}
+	if _, err := io.Copy(compressor, io.TeeReader(src, header)); err != nil {
+		return nil, fmt.Errorf("failed to write backup file: %w", err)
+	}
+	if err := compressor.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish %s compression: %w", p.codec.name, err)
+	}
+	if err := encryptor.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish encryption: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return nil, fmt.Errorf("failed to sync backup file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close backup file: %w", err)
+	}
+
+	return &pipelineResult{
+		size:     counter.n,
+		checksum: hex.EncodeToString(hash.Sum(nil)),
+		header:   header.buf,
+	}, nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
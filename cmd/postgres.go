@@ -0,0 +1,551 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// postgresEngine backs up PostgreSQL databases with pg_dump and restores them with pg_restore
+type postgresEngine struct{}
+
+// libpqParamEnv maps the libpq connection parameters we pass through to the
+// environment variables understood by every PostgreSQL client tool
+var libpqParamEnv = map[string]string{
+	"application_name":         "PGAPPNAME",
+	"channel_binding":          "PGCHANNELBINDING",
+	"client_encoding":          "PGCLIENTENCODING",
+	"connect_timeout":          "PGCONNECT_TIMEOUT",
+	"gssencmode":               "PGGSSENCMODE",
+	"krbsrvname":               "PGKRBSRVNAME",
+	"options":                  "PGOPTIONS",
+	"passfile":                 "PGPASSFILE",
+	"require_auth":             "PGREQUIREAUTH",
+	"service":                  "PGSERVICE",
+	"sslcert":                  "PGSSLCERT",
+	"sslcrl":                   "PGSSLCRL",
+	"sslkey":                   "PGSSLKEY",
+	"sslmode":                  "PGSSLMODE",
+	"sslrootcert":              "PGSSLROOTCERT",
+	"ssl_min_protocol_version": "PGSSLMINPROTOCOLVERSION",
+	"ssl_max_protocol_version": "PGSSLMAXPROTOCOLVERSION",
+	"target_session_attrs":     "PGTARGETSESSIONATTRS",
+}
+
+// pgDumpFormats maps the --format names to pg_dump's -F letters and the backup file extensions
+var pgDumpFormats = map[string]struct {
+	flag string
+	ext  string
+}{
+	"custom":    {"c", ".dump"},
+	"plain":     {"p", ".sql"},
+	"directory": {"d", ".dir"},
+	"tar":       {"t", ".tar"},
+}
+
+// pgPlainDumpTrailer ends every plain-format dump that pg_dump finished writing
+var pgPlainDumpTrailer = []byte("-- PostgreSQL database dump complete")
+
+func (postgresEngine) name() string        { return "postgres" }
+func (postgresEngine) defaultPort() string { return "5432" }
+
+func (postgresEngine) dumpExt(format string) string {
+	if format == "" {
+		format = "custom"
+	}
+	return pgDumpFormats[format].ext
+}
+
+func (postgresEngine) checkParam(key string) error {
+	if _, ok := libpqParamEnv[key]; !ok {
+		return fmt.Errorf("unsupported connection parameter %q", key)
+	}
+	return nil
+}
+
+// env returns the process environment extended with the extra libpq
+// parameters and, when there is a password, PGPASSFILE pointing to a private
+// password file holding it, which the returned function removes. Unlike
+// PGPASSWORD, the file cannot be read from the tools' environment.
+func (postgresEngine) env(conn *dbConnection) ([]string, func(), error) {
+	if conn.password == "" {
+		return append(os.Environ(), pgParamEnv(conn)...), func() {}, nil
+	}
+
+	file, err := os.CreateTemp("", "omti-pgpass-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create PostgreSQL password file: %w", err)
+	}
+	defer file.Close()
+	if _, err := fmt.Fprintf(file, "*:*:*:*:%s\n", pgpassEscape(conn.password)); err != nil {
+		os.Remove(file.Name())
+		return nil, nil, fmt.Errorf("failed to write PostgreSQL password file: %w", err)
+	}
+
+	// libpq prefers PGPASSWORD to the file, drop one set for omti itself
+	var env []string
+	for _, variable := range os.Environ() {
+		if !strings.HasPrefix(variable, "PGPASSWORD=") && !strings.HasPrefix(variable, "PGPASSFILE=") {
+			env = append(env, variable)
+		}
+	}
+	env = append(env, pgParamEnv(conn)...)
+	return append(env, "PGPASSFILE="+file.Name()), func() { os.Remove(file.Name()) }, nil
+}
+
+// pgpassEscape escapes the backslashes and colons of a password file field
+func pgpassEscape(field string) string {
+	return strings.NewReplacer(`\`, `\\`, ":", `\:`).Replace(field)
+}
+
+// pgParamEnv returns the NAME=value environment variables of the extra libpq parameters
+func pgParamEnv(conn *dbConnection) []string {
+	keys := make([]string, 0, len(conn.params))
+	for key := range conn.params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var env []string
+	for _, key := range keys {
+		env = append(env, fmt.Sprintf("%s=%s", libpqParamEnv[key], conn.params[key]))
+	}
+	return env
+}
+
+// pgArgs returns the -h/-p/-U arguments shared by the PostgreSQL client tools
+func pgArgs(conn *dbConnection) []string {
+	var args []string
+	if conn.host != "" {
+		args = append(args, "-h", conn.host)
+	}
+	args = append(args, "-p", conn.port)
+	if conn.user != "" {
+		args = append(args, "-U", conn.user)
+	}
+	return args
+}
+
+// checkDump accepts every filter, they map directly to pg_dump options. Only
+// the directory format can be dumped in parallel.
+func (postgresEngine) checkDump(opts dumpOptions) error {
+	if _, ok := pgDumpFormats[opts.format]; !ok && opts.format != "" {
+		return fmt.Errorf("unsupported dump format %q, expected custom, plain, directory or tar", opts.format)
+	}
+	if opts.jobs > 1 && opts.format != "directory" {
+		return fmt.Errorf("--jobs requires --format directory")
+	}
+	return nil
+}
+
+// pgDumpFilterArgs returns the pg_dump options for the object filters
+func pgDumpFilterArgs(filters dumpFilters) []string {
+	var args []string
+	for _, option := range []struct {
+		name     string
+		patterns []string
+	}{
+		{"--schema", filters.Schemas},
+		{"--exclude-schema", filters.ExcludeSchemas},
+		{"--table", filters.Tables},
+		{"--exclude-table", filters.ExcludeTables},
+		{"--exclude-table-data", filters.ExcludeTableData},
+	} {
+		for _, pattern := range option.patterns {
+			args = append(args, option.name+"="+pattern)
+		}
+	}
+	if filters.SchemaOnly {
+		args = append(args, "--schema-only")
+	}
+	if filters.DataOnly {
+		args = append(args, "--data-only")
+	}
+	return args
+}
+
+// pgDumpArgs returns the pg_dump arguments for a dump to stdout in the custom, plain or tar format
+func pgDumpArgs(conn *dbConnection, opts dumpOptions) []string {
+	format := opts.format
+	if format == "" {
+		format = "custom"
+	}
+	args := append(pgArgs(conn),
+		"-d", conn.dbName,
+		"-F", pgDumpFormats[format].flag,
+	)
+	args = append(args, pgDumpFilterArgs(opts.filters)...)
+	if opts.compressed && format == "custom" {
+		// Leave compression to the codec instead of compressing twice
+		args = append(args, "-Z", "0")
+	}
+	return args
+}
+
+// pgDumpTablePattern matches the --verbose log line pg_dump writes as it starts dumping a table's rows
+var pgDumpTablePattern = regexp.MustCompile(`^pg_dump: dumping contents of table "?([^"]+)"?$`)
+
+// dump runs pg_dump in the custom, plain or tar format. When the progress is
+// reported, pg_dump runs with --verbose so its log names the current table.
+func (postgresEngine) dump(ctx context.Context, conn *dbConnection, opts dumpOptions) (io.ReadCloser, error) {
+	args := pgDumpArgs(conn, opts)
+	if opts.progress == nil {
+		return startDumpCommand(ctx, conn, nil, "pg_dump", args...)
+	}
+	return startDumpCommand(ctx, conn, &toolLogFilter{
+		tool:     "pg_dump",
+		table:    pgDumpTablePattern,
+		progress: opts.progress,
+	}, "pg_dump", append(args, "--verbose")...)
+}
+
+// dumpSize estimates the size of an uncompressed dump by the on-disk size of
+// the database from pg_database_size. pg_dump compresses custom-format dumps
+// unless the backup pipeline does, and directory dumps always, and their
+// output bears no useful relation to the size of the database.
+func (postgresEngine) dumpSize(ctx context.Context, conn *dbConnection, opts dumpOptions) (int64, error) {
+	switch opts.format {
+	case "plain", "tar":
+	case "", "custom":
+		if !opts.compressed {
+			return 0, nil
+		}
+	default:
+		return 0, nil
+	}
+
+	args := append(pgArgs(conn),
+		"-d", conn.dbName,
+		"-X",
+		"-A",
+		"-t",
+		"-c", "SELECT pg_database_size(current_database())",
+	)
+	output, err := dbToolOutput(ctx, "psql", conn, nil, args...)
+	if err != nil {
+		return 0, err
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected database size %q", strings.TrimSpace(string(output)))
+	}
+	return size, nil
+}
+
+// dumpOnRemote runs pg_dump on the remote host and streams its output back.
+// The password is sent over the session's stdin rather than on the command
+// line, where other users of the remote host could see it.
+func (postgresEngine) dumpOnRemote(ctx context.Context, conn *dbConnection, remoteUser, remoteHost string, opts dumpOptions) (io.ReadCloser, error) {
+	command := []string{"exec", "env"}
+	for _, env := range pgParamEnv(conn) {
+		command = append(command, shellQuote(env))
+	}
+	command = append(command, "pg_dump")
+	for _, arg := range pgDumpArgs(conn, opts) {
+		command = append(command, shellQuote(arg))
+	}
+
+	script := strings.Join(command, " ")
+	var stdin io.Reader
+	if conn.password != "" {
+		script = "IFS= read -r PGPASSWORD && export PGPASSWORD && " + script
+		stdin = strings.NewReader(conn.password + "\n")
+	}
+	return startSSHCommand(ctx, remoteUser, remoteHost, "pg_dump", script, stdin)
+}
+
+// dumpToDirectory runs pg_dump in the directory format with opts.jobs parallel jobs
+func (postgresEngine) dumpToDirectory(ctx context.Context, conn *dbConnection, dir string, opts dumpOptions) ([]byte, error) {
+	args := append(pgArgs(conn),
+		"-d", conn.dbName,
+		"-F", "d",
+		"-j", strconv.Itoa(max(opts.jobs, 1)),
+		"-f", dir,
+	)
+	args = append(args, pgDumpFilterArgs(opts.filters)...)
+	if err := runDBTool(ctx, "pg_dump", conn, args...); err != nil {
+		return nil, err
+	}
+	return readTOCHeader(dir)
+}
+
+// readTOCHeader returns the start of the table of contents of a directory dump
+func readTOCHeader(dir string) ([]byte, error) {
+	toc, err := os.Open(filepath.Join(dir, "toc.dat"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table of contents: %w", err)
+	}
+	defer toc.Close()
+	head := make([]byte, archiveHeaderSize)
+	n, _ := io.ReadFull(toc, head)
+	return head[:n], nil
+}
+
+// pgArchiveFormat tells the dump formats apart by their first bytes. Custom
+// archives and the toc.dat of directory dumps start with PGDMP and record their
+// format; tar archives carry the ustar magic; anything else is a plain SQL script.
+func pgArchiveFormat(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("PGDMP")):
+		if len(head) > 10 && head[10] == 5 {
+			return "directory"
+		}
+		return "custom"
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return "tar"
+	}
+	return "plain"
+}
+
+var (
+	pgPlainServerVersionPattern = regexp.MustCompile(`(?m)^-- Dumped from database version (\S+)`)
+	pgPlainDumpVersionPattern   = regexp.MustCompile(`(?m)^-- Dumped by pg_dump version (\S+)`)
+)
+
+// describeDump reads the format and versions recorded in the dump header. The
+// first member of a tar archive is the table of contents in the custom format.
+func (postgresEngine) describeDump(ctx context.Context, head []byte, manifest *backupManifest) {
+	manifest.Format = pgArchiveFormat(head)
+	switch manifest.Format {
+	case "plain":
+		if match := pgPlainServerVersionPattern.FindSubmatch(head); match != nil {
+			manifest.ServerVersion = string(match[1])
+		}
+		if match := pgPlainDumpVersionPattern.FindSubmatch(head); match != nil {
+			manifest.PgDumpVersion = string(match[1])
+		}
+	case "tar":
+		if len(head) > 512 {
+			manifest.ServerVersion, manifest.PgDumpVersion = parseArchiveVersions(head[512:])
+		}
+	default:
+		manifest.ServerVersion, manifest.PgDumpVersion = parseArchiveVersions(head)
+	}
+	if manifest.PgDumpVersion == "" {
+		manifest.PgDumpVersion = pgDumpVersion(ctx)
+	}
+}
+
+func (postgresEngine) checkRestore(opts restoreOptions) error {
+	return nil
+}
+
+// createDatabase creates the target database with createdb, dropping an existing one with dropdb first
+func (e postgresEngine) createDatabase(ctx context.Context, conn *dbConnection, dropExisting bool) error {
+	if dropExisting {
+		if err := e.dropDatabase(ctx, conn); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Dropped existing database %s\n", conn.dbName)
+	}
+
+	createArgs := append(pgArgs(conn), conn.dbName)
+	if err := runDBTool(ctx, "createdb", conn, createArgs...); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Created database %s\n", conn.dbName)
+	return nil
+}
+
+// pgRestoreTablePattern matches the --verbose log line pg_restore writes as it starts restoring a table's rows
+var pgRestoreTablePattern = regexp.MustCompile(`^pg_restore: processing data for table "?([^"]+)"?$`)
+
+// restore feeds the archive to pg_restore with the requested options, or to
+// psql for plain-format dumps. Unpacked local archives and directory dumps are
+// read by pg_restore directly; parallel restores need a seekable archive, so
+// compressed local ones are unpacked to a temporary file next to the backup
+// first. Encrypted backups and those in object storage are restored with a
+// single job instead, a temporary file would hold the decrypted dump or land
+// in the shared system temp dir.
+func (e postgresEngine) restore(ctx context.Context, conn *dbConnection, backupFile string, archive io.Reader, info backupArchive, opts restoreOptions) error {
+	if archive != nil {
+		buffered := bufio.NewReaderSize(archive, archiveHeaderSize)
+		head, _ := buffered.Peek(archiveHeaderSize)
+		if pgArchiveFormat(head) == "plain" {
+			return e.restorePlain(ctx, conn, buffered, info, opts)
+		}
+		archive = buffered
+	}
+
+	fromFile := archive == nil || !info.transformed() && !isS3URL(backupFile)
+	jobs := opts.jobs
+	if jobs > 1 && !fromFile && (info.encrypted || isS3URL(backupFile)) {
+		fmt.Printf("⚠️ Restoring %s with a single job, --jobs needs an unencrypted local backup\n", info)
+		jobs = 1
+	}
+
+	args := append(pgArgs(conn),
+		"-d", conn.dbName,
+		"-j", strconv.Itoa(jobs),
+	)
+	if opts.clean {
+		args = append(args, "--clean", "--if-exists")
+	}
+	if opts.schema != "" {
+		args = append(args, "-n", opts.schema)
+	}
+	if opts.table != "" {
+		args = append(args, "-t", opts.table)
+	}
+	if opts.progress != nil {
+		// --verbose names each table as its rows are restored
+		args = append(args, "--verbose")
+	}
+
+	var stdin io.Reader
+	switch {
+	case fromFile:
+		opts.progress.untrack()
+		args = append(args, backupFile)
+	case jobs > 1:
+		fmt.Printf("📦 Unpacking %s to a temporary file for parallel restore\n", info)
+		tempFile, cleanup, err := decompressToTemp(archive, backupFile)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		args = append(args, tempFile)
+	default:
+		fmt.Printf("📦 Streaming %s into pg_restore\n", info)
+		stdin = archive
+	}
+
+	if opts.progress == nil {
+		return runDBToolWithInput(ctx, "pg_restore", conn, stdin, args...)
+	}
+	return runLoggedDBTool(ctx, "pg_restore", conn, stdin, &toolLogFilter{
+		tool:     "pg_restore",
+		table:    pgRestoreTablePattern,
+		progress: opts.progress,
+	}, args...)
+}
+
+// restorePlain runs a plain-format dump with psql, stopping at the first error.
+// The script restores the whole dump, so the pg_restore selection options do not apply.
+func (postgresEngine) restorePlain(ctx context.Context, conn *dbConnection, script io.Reader, info backupArchive, opts restoreOptions) error {
+	switch {
+	case opts.schema != "" || opts.table != "":
+		return fmt.Errorf("--schema and --table are not supported for plain-format dumps")
+	case opts.jobs > 1:
+		return fmt.Errorf("--jobs is not supported for plain-format dumps")
+	case opts.clean && !opts.create:
+		return fmt.Errorf("--clean is not supported for plain-format dumps, use --create --clean to recreate the database")
+	}
+
+	args := append(pgArgs(conn),
+		"-d", conn.dbName,
+		"-X",
+		"-q",
+		"-v", "ON_ERROR_STOP=1",
+		"-f", "-",
+	)
+	fmt.Printf("📦 Streaming %s into psql\n", info)
+	return runDBToolWithInput(ctx, "psql", conn, script, args...)
+}
+
+// restoreStream feeds a custom-format dump to pg_restore as pg_dump writes it.
+// The archive cannot be seeked, so it is restored by a single job.
+func (postgresEngine) restoreStream(ctx context.Context, conn *dbConnection, dump io.Reader, opts cloneOptions) error {
+	args := append(pgArgs(conn), "-d", conn.dbName)
+	if opts.noOwner {
+		args = append(args, "--no-owner")
+	}
+	return runDBToolWithInput(ctx, "pg_restore", conn, dump, args...)
+}
+
+// dropDatabase drops the database with dropdb
+func (postgresEngine) dropDatabase(ctx context.Context, conn *dbConnection) error {
+	dropArgs := append(pgArgs(conn), "--if-exists", conn.dbName)
+	return runDBTool(ctx, "dropdb", conn, dropArgs...)
+}
+
+// verifyArchive reads the archive's table of contents with pg_restore --list,
+// or checks that a plain-format dump ends with pg_dump's completion trailer
+func (postgresEngine) verifyArchive(ctx context.Context, archive io.Reader) (string, error) {
+	buffered := bufio.NewReaderSize(archive, archiveHeaderSize)
+	head, _ := buffered.Peek(archiveHeaderSize)
+	if pgArchiveFormat(head) != "plain" {
+		return pgRestoreList(ctx, buffered)
+	}
+
+	tail, size, err := readTail(buffered, 4096)
+	if err != nil {
+		return "", fmt.Errorf("failed to read dump: %w", err)
+	}
+	if !bytes.Contains(tail, pgPlainDumpTrailer) {
+		return "", fmt.Errorf("dump does not end with %q, it may be truncated", pgPlainDumpTrailer)
+	}
+	return fmt.Sprintf("complete SQL dump of %s", formatBytes(size)), nil
+}
+
+// verifyDirectory reads the directory dump's table of contents with pg_restore --list
+func (postgresEngine) verifyDirectory(ctx context.Context, dir string) (string, error) {
+	return pgRestoreList(ctx, nil, dir)
+}
+
+// pgRestoreList counts the entries pg_restore --list finds in the archive read
+// from stdin or named in args
+func pgRestoreList(ctx context.Context, stdin io.Reader, args ...string) (string, error) {
+	output, err := dbToolOutput(ctx, "pg_restore", nil, stdin, append([]string{"--list"}, args...)...)
+	if err != nil {
+		return "", err
+	}
+
+	entries := 0
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, ";") {
+			entries++
+		}
+	}
+	if entries == 0 {
+		return "", fmt.Errorf("pg_restore --list found no TOC entries")
+	}
+	return fmt.Sprintf("pg_restore --list read %d TOC entries", entries), nil
+}
+
+// pgRowCountQuery counts the rows of every user table in one statement, using
+// query_to_xml to run a count(*) per table
+const pgRowCountQuery = `SELECT format('%I.%I', table_schema, table_name),
+       (xpath('/row/c/text()', query_to_xml(format('SELECT count(*) AS c FROM %I.%I', table_schema, table_name), false, true, '')))[1]::text
+  FROM information_schema.tables
+ WHERE table_type = 'BASE TABLE' AND table_schema NOT IN ('pg_catalog', 'information_schema')
+ ORDER BY 1`
+
+// countRows counts the rows of every user table with psql
+func (postgresEngine) countRows(ctx context.Context, conn *dbConnection) ([]tableRows, error) {
+	args := append(pgArgs(conn),
+		"-d", conn.dbName,
+		"-X",
+		"-A",
+		"-t",
+		"-F", "\t",
+		"-v", "ON_ERROR_STOP=1",
+		"-c", pgRowCountQuery,
+	)
+	output, err := dbToolOutput(ctx, "psql", conn, nil, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var counts []tableRows
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		table, value, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		rows, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected row count %q for %s", value, table)
+		}
+		counts = append(counts, tableRows{table: table, rows: rows})
+	}
+	return counts, nil
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPgDumpFilterArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters dumpFilters
+		want    []string
+	}{
+		{name: "no filters"},
+		{
+			name:    "schemas",
+			filters: dumpFilters{Schemas: []string{"public", "billing"}, ExcludeSchemas: []string{"audit*"}},
+			want:    []string{"--schema=public", "--schema=billing", "--exclude-schema=audit*"},
+		},
+		{
+			name:    "tables",
+			filters: dumpFilters{Tables: []string{"public.orders"}, ExcludeTables: []string{"tmp_*"}, ExcludeTableData: []string{"public.events", "logs"}},
+			want:    []string{"--table=public.orders", "--exclude-table=tmp_*", "--exclude-table-data=public.events", "--exclude-table-data=logs"},
+		},
+		{
+			name:    "pattern with spaces is one argument",
+			filters: dumpFilters{Tables: []string{`"Order Items"`}},
+			want:    []string{`--table="Order Items"`},
+		},
+		{name: "schema only", filters: dumpFilters{SchemaOnly: true}, want: []string{"--schema-only"}},
+		{
+			name:    "data only after the patterns",
+			filters: dumpFilters{DataOnly: true, Schemas: []string{"public"}},
+			want:    []string{"--schema=public", "--data-only"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pgDumpFilterArgs(tt.filters); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pgDumpFilterArgs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPgDumpFormats(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		jobs       int
+		compressed bool
+		wantExt    string
+		wantArgs   []string
+		wantErr    string
+	}{
+		{name: "custom by default", wantExt: ".dump", wantArgs: []string{"-F", "c"}},
+		{name: "compressed custom", compressed: true, wantExt: ".dump", wantArgs: []string{"-F", "c", "-Z", "0"}},
+		{name: "explicit custom", format: "custom", compressed: true, wantExt: ".dump", wantArgs: []string{"-F", "c", "-Z", "0"}},
+		{name: "compressed plain", format: "plain", compressed: true, wantExt: ".sql", wantArgs: []string{"-F", "p"}},
+		{name: "tar", format: "tar", wantExt: ".tar", wantArgs: []string{"-F", "t"}},
+		{name: "parallel directory", format: "directory", jobs: 4, wantExt: ".dir"},
+		{name: "parallel plain", format: "plain", jobs: 4, wantErr: "--jobs requires --format directory"},
+		{name: "parallel custom", jobs: 2, wantErr: "--jobs requires --format directory"},
+		{name: "unknown format", format: "sql", wantErr: `unsupported dump format "sql", expected custom, plain, directory or tar`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := dumpOptions{format: tt.format, jobs: tt.jobs, compressed: tt.compressed}
+			err := postgresEngine{}.checkDump(opts)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("checkDump error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkDump failed: %v", err)
+			}
+
+			if ext := (postgresEngine{}).dumpExt(tt.format); ext != tt.wantExt {
+				t.Errorf("dumpExt(%q) = %q, want %q", tt.format, ext, tt.wantExt)
+			}
+			if tt.wantArgs == nil {
+				return
+			}
+			conn := &dbConnection{host: "localhost", port: "5432", user: "postgres", dbName: "golang"}
+			want := append([]string{"-h", "localhost", "-p", "5432", "-U", "postgres", "-d", "golang"}, tt.wantArgs...)
+			if got := pgDumpArgs(conn, opts); !reflect.DeepEqual(got, want) {
+				t.Errorf("pgDumpArgs = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestPgArchiveFormat(t *testing.T) {
+	tar := make([]byte, 512)
+	copy(tar, "toc.dat")
+	copy(tar[257:], "ustar")
+
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{name: "custom archive", head: []byte("PGDMP\x01\x0f\x00\x04\x08\x01"), want: "custom"},
+		{name: "directory table of contents", head: []byte("PGDMP\x01\x0f\x00\x04\x08\x05"), want: "directory"},
+		{name: "short header", head: []byte("PGDMP"), want: "custom"},
+		{name: "tar archive", head: tar, want: "tar"},
+		{name: "plain dump", head: []byte("--\n-- PostgreSQL database dump\n--\n"), want: "plain"},
+		{name: "empty", want: "plain"},
+	}
+	for _, tt := range tests {
+		if got := pgArchiveFormat(tt.head); got != tt.want {
+			t.Errorf("%s: pgArchiveFormat = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
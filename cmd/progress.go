@@ -0,0 +1,368 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressModes are the accepted --progress values. auto picks live on a
+// terminal and log otherwise; json writes one event per line for CI logs.
+var progressModes = []string{"auto", "live", "log", "json", "none"}
+
+const (
+	// liveProgressInterval is how often the live status line is redrawn
+	liveProgressInterval = 500 * time.Millisecond
+	// logProgressInterval is how often a progress line or event is written in the log and json modes
+	logProgressInterval = 10 * time.Second
+)
+
+var progressFlag string
+
+// checkProgressMode rejects unknown --progress values
+func checkProgressMode(mode string) error {
+	if mode == "" {
+		return nil
+	}
+	for _, known := range progressModes {
+		if mode == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not one of %s", mode, strings.Join(progressModes, ", "))
+}
+
+// resolveProgressMode turns auto (or an unset flag) into live or log depending
+// on whether stderr is a terminal
+func resolveProgressMode(mode string) string {
+	if mode != "" && mode != "auto" {
+		return mode
+	}
+	if info, err := os.Stderr.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		return "live"
+	}
+	return "log"
+}
+
+// progressEvent is one line of the --progress json event stream
+type progressEvent struct {
+	Time           time.Time `json:"time"`
+	Event          string    `json:"event"`
+	Operation      string    `json:"operation"`
+	Database       string    `json:"database"`
+	Bytes          int64     `json:"bytes"`
+	TotalBytes     int64     `json:"total_bytes,omitempty"`
+	Percent        float64   `json:"percent,omitempty"`
+	ElapsedSeconds float64   `json:"elapsed_seconds"`
+	BytesPerSecond float64   `json:"bytes_per_second"`
+	Table          string    `json:"table,omitempty"`
+	Error          string    `json:"error,omitempty"`
+
+	// untracked is set when no bytes were counted
+	untracked bool
+}
+
+// progressReporter reports the bytes flowing through a backup or restore on
+// stderr while it runs. Writes count the bytes; total, when known, turns them
+// into an estimated percentage. A nil reporter reports nothing.
+type progressReporter struct {
+	operation string
+	database  string
+	mode      string
+	total     int64
+	start     time.Time
+	out       io.Writer
+
+	bytes atomic.Int64
+	// poll, when set, measures the bytes written so far instead of Write
+	poll func() int64
+	// untracked is set when a tool reads the backup itself, so no bytes are counted
+	untracked bool
+
+	mu    sync.Mutex
+	table string
+
+	// emitMu serializes emit, which runs on the ticker goroutine and on the
+	// goroutine reading the tool log
+	emitMu sync.Mutex
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// newProgressReporter starts reporting the progress of operation ("backup",
+// "restore" or "clone") on database. total is the expected number of bytes, or 0 if unknown.
+func newProgressReporter(operation, database string, total int64) *progressReporter {
+	mode := resolveProgressMode(progressFlag)
+	if mode == "none" {
+		return nil
+	}
+
+	p := &progressReporter{
+		operation: operation,
+		database:  database,
+		mode:      mode,
+		total:     total,
+		start:     time.Now(),
+		out:       os.Stderr,
+		stop:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	p.emit("start", nil)
+	go p.run()
+	return p
+}
+
+// Write counts bytes passing through the operation, e.g. from an io.TeeReader
+func (p *progressReporter) Write(b []byte) (int, error) {
+	if p != nil {
+		p.bytes.Add(int64(len(b)))
+	}
+	return len(b), nil
+}
+
+// watchDir measures the progress by the size of a directory being written
+// by a tool, for directory dumps that do not stream through omti
+func (p *progressReporter) watchDir(dir string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.poll = func() int64 {
+		size, _ := dirSize(dir)
+		return size
+	}
+}
+
+// untrack stops reporting bytes, for tools that read the backup file themselves
+func (p *progressReporter) untrack() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.untracked = true
+}
+
+// setTable records the table the tool is working on
+func (p *progressReporter) setTable(table string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	changed := p.table != table
+	p.table = table
+	p.mu.Unlock()
+	if changed && p.mode == "json" {
+		p.emit("table", nil)
+	}
+}
+
+// finish stops the periodic reports and reports the outcome of the operation
+func (p *progressReporter) finish(err error) {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	<-p.stopped
+
+	if err != nil {
+		p.emit("error", err)
+		return
+	}
+	p.emit("finish", nil)
+}
+
+func (p *progressReporter) run() {
+	defer close(p.stopped)
+
+	interval := logProgressInterval
+	if p.mode == "live" {
+		interval = liveProgressInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.emit("progress", nil)
+		}
+	}
+}
+
+// snapshot returns the current state of the operation as an event
+func (p *progressReporter) snapshot(event string, err error) progressEvent {
+	p.mu.Lock()
+	table, poll, untracked := p.table, p.poll, p.untracked
+	p.mu.Unlock()
+
+	n := p.bytes.Load()
+	if poll != nil {
+		n = poll()
+	}
+	elapsed := time.Since(p.start)
+	e := progressEvent{
+		Time:           time.Now().UTC(),
+		Event:          event,
+		Operation:      p.operation,
+		Database:       p.database,
+		Bytes:          n,
+		TotalBytes:     p.total,
+		ElapsedSeconds: elapsed.Seconds(),
+		Table:          table,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	if untracked {
+		e.Bytes, e.TotalBytes, e.untracked = 0, 0, true
+		return e
+	}
+	if elapsed > 0 {
+		e.BytesPerSecond = float64(n) / elapsed.Seconds()
+	}
+	if p.total > 0 {
+		// The estimate is rarely exact, so only a finished operation reaches 100%
+		e.Percent = min(math.Round(float64(n)/float64(p.total)*1000)/10, 99)
+		if event == "finish" {
+			e.Percent = 100
+		}
+	}
+	return e
+}
+
+// emit writes an event in the reporter's mode
+func (p *progressReporter) emit(event string, err error) {
+	p.emitMu.Lock()
+	defer p.emitMu.Unlock()
+	e := p.snapshot(event, err)
+
+	switch p.mode {
+	case "json":
+		data, _ := json.Marshal(e)
+		fmt.Fprintf(p.out, "%s\n", data)
+	case "live":
+		switch event {
+		case "start":
+		case "progress":
+			fmt.Fprintf(p.out, "\r\033[K⏳ %s", p.describe(e))
+		default:
+			fmt.Fprintf(p.out, "\r\033[K")
+			p.summarize(e)
+		}
+	default:
+		switch event {
+		case "start":
+		case "progress":
+			fmt.Fprintf(p.out, "⏳ %s\n", p.describe(e))
+		default:
+			p.summarize(e)
+		}
+	}
+}
+
+// describe formats an event as a status line, e.g.
+// "backup of golang: 1.2 GiB, 25.3 MiB/s, 1m12s, ~45%, public.orders"
+func (p *progressReporter) describe(e progressEvent) string {
+	elapsed := time.Duration(e.ElapsedSeconds * float64(time.Second)).Round(time.Second)
+	if e.untracked {
+		line := fmt.Sprintf("%s of %s: %s", e.Operation, e.Database, elapsed)
+		if e.Table != "" {
+			line += ", " + e.Table
+		}
+		return line
+	}
+
+	line := fmt.Sprintf("%s of %s: %s, %s/s, %s",
+		e.Operation,
+		e.Database,
+		formatBytes(e.Bytes),
+		formatBytes(int64(e.BytesPerSecond)),
+		elapsed,
+	)
+	if e.TotalBytes > 0 {
+		line += fmt.Sprintf(", ~%.0f%%", e.Percent)
+	}
+	if e.Table != "" {
+		line += ", " + e.Table
+	}
+	return line
+}
+
+// summarize prints the final line of a finished operation
+func (p *progressReporter) summarize(e progressEvent) {
+	elapsed := time.Duration(e.ElapsedSeconds * float64(time.Second)).Round(time.Millisecond)
+	switch {
+	case e.Error != "":
+		return
+	case e.untracked:
+		fmt.Fprintf(p.out, "📊 %s of %s: done in %s\n", e.Operation, e.Database, elapsed)
+		return
+	}
+	fmt.Fprintf(p.out, "📊 %s of %s: %s in %s (%s/s)\n",
+		e.Operation,
+		e.Database,
+		formatBytes(e.Bytes),
+		elapsed,
+		formatBytes(int64(e.BytesPerSecond)),
+	)
+}
+
+// toolLogFilter reads the --verbose log of a client tool line by line. Lines
+// naming the table being processed are reported to the progress reporter, and
+// only the tool's warnings and errors are kept for error messages.
+type toolLogFilter struct {
+	// tool is the prefix of the tool's log lines, e.g. "pg_dump"
+	tool string
+	// table captures the table name from a log line
+	table    *regexp.Regexp
+	progress *progressReporter
+
+	out     io.Writer
+	partial []byte
+}
+
+func (f *toolLogFilter) Write(b []byte) (int, error) {
+	f.partial = append(f.partial, b...)
+	for {
+		i := bytes.IndexByte(f.partial, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		f.line(string(f.partial[:i+1]))
+		f.partial = f.partial[i+1:]
+	}
+}
+
+// flush handles a last line that did not end with a newline
+func (f *toolLogFilter) flush() {
+	if len(f.partial) > 0 {
+		f.line(string(f.partial))
+		f.partial = nil
+	}
+}
+
+func (f *toolLogFilter) line(line string) {
+	if match := f.table.FindStringSubmatch(strings.TrimRight(line, "\r\n")); match != nil {
+		f.progress.setTable(match[1])
+		return
+	}
+	message, ok := strings.CutPrefix(line, f.tool+": ")
+	if ok && !strings.HasPrefix(message, "error:") && !strings.HasPrefix(message, "warning:") &&
+		!strings.HasPrefix(message, "detail:") && !strings.HasPrefix(message, "hint:") {
+		return
+	}
+	io.WriteString(f.out, line)
+}
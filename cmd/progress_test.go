@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// jsonProgressReporter returns a reporter writing json events to out without
+// the periodic reports
+func jsonProgressReporter(out *bytes.Buffer) *progressReporter {
+	return &progressReporter{operation: "backup", database: "golang", mode: "json", start: time.Now(), out: out}
+}
+
+// progressEvents parses the json events written by a reporter
+func progressEvents(t *testing.T, out *bytes.Buffer) []progressEvent {
+	var events []progressEvent
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		var e progressEvent
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("invalid progress event %q: %v", line, err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestToolLogFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		tool       string
+		table      *regexp.Regexp
+		log        string
+		wantOut    string
+		wantTables []string
+	}{
+		{
+			name:  "pg_dump",
+			tool:  "pg_dump",
+			table: pgDumpTablePattern,
+			log: "pg_dump: last built-in OID is 16383\n" +
+				"pg_dump: reading extensions\n" +
+				`pg_dump: dumping contents of table "public.orders"` + "\n" +
+				"pg_dump: warning: there are circular foreign-key constraints on this table:\n" +
+				"pg_dump: detail: orders\n" +
+				"pg_dump: hint: Consider using a full dump instead of a --data-only dump to avoid this problem.\n" +
+				"pg_dump: dumping contents of table public.items\r\n" +
+				"pg_dump: error: query failed: ERROR:  permission denied for table secrets\n" +
+				"could not write to output file: No space left on device\n",
+			wantOut: "pg_dump: warning: there are circular foreign-key constraints on this table:\n" +
+				"pg_dump: detail: orders\n" +
+				"pg_dump: hint: Consider using a full dump instead of a --data-only dump to avoid this problem.\n" +
+				"pg_dump: error: query failed: ERROR:  permission denied for table secrets\n" +
+				"could not write to output file: No space left on device\n",
+			wantTables: []string{"public.orders", "public.items"},
+		},
+		{
+			name:  "pg_restore with an unterminated last line",
+			tool:  "pg_restore",
+			table: pgRestoreTablePattern,
+			log: "pg_restore: connecting to database for restore\n" +
+				`pg_restore: processing data for table "public.orders"` + "\n" +
+				`pg_restore: processing data for table "public.orders"` + "\n" +
+				"pg_restore: error: could not execute query: ERROR:  relation \"orders\" already exists",
+			wantOut:    "pg_restore: error: could not execute query: ERROR:  relation \"orders\" already exists",
+			wantTables: []string{"public.orders"},
+		},
+		{
+			name:    "another tool's prefix is kept",
+			tool:    "pg_dump",
+			table:   pgDumpTablePattern,
+			log:     "pg_restore: reading extensions\n",
+			wantOut: "pg_restore: reading extensions\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, events bytes.Buffer
+			filter := &toolLogFilter{
+				tool:     tt.tool,
+				table:    tt.table,
+				progress: jsonProgressReporter(&events),
+				out:      &out,
+			}
+			// Tools write their log in arbitrary pieces
+			for log := []byte(tt.log); len(log) > 0; log = log[min(len(log), 7):] {
+				filter.Write(log[:min(len(log), 7)])
+			}
+			filter.flush()
+
+			if out.String() != tt.wantOut {
+				t.Errorf("toolLogFilter kept\n%s\nwant\n%s", out.String(), tt.wantOut)
+			}
+			var tables []string
+			for _, e := range progressEvents(t, &events) {
+				if e.Event == "table" {
+					tables = append(tables, e.Table)
+				}
+			}
+			if fmt.Sprint(tables) != fmt.Sprint(tt.wantTables) {
+				t.Errorf("toolLogFilter reported tables %q, want %q", tables, tt.wantTables)
+			}
+		})
+	}
+}
+
+func TestProgressReporterConcurrentEvents(t *testing.T) {
+	var out bytes.Buffer
+	p := jsonProgressReporter(&out)
+
+	// The ticker reports progress while the tool log reports tables
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			p.emit("progress", nil)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			p.setTable(fmt.Sprintf("public.table_%d", i))
+		}
+	}()
+	wg.Wait()
+
+	if events := progressEvents(t, &out); len(events) != 2000 {
+		t.Errorf("reporter wrote %d events, want 2000", len(events))
+	}
+}
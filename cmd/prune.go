@@ -0,0 +1,321 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// pruneCmd represents the command to delete old backups according to a retention policy
+var pruneCmd = &cobra.Command{
+	Use:   "prune <local_save_path>",
+	Short: "Delete old backups that fall outside the retention policy",
+	Long: `Delete old backups that fall outside the retention policy.
+
+A backup is kept if any rule keeps it, e.g.
+--keep-last 3 --keep-daily 7 --keep-weekly 4 --keep-monthly 12`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		localSavePath := args[0]
+
+		logger := createCustomLogger()
+		logger.Info("🚀 Starting backup pruning process")
+
+		if err := retention.validate(); err != nil {
+			logger.Fatalf("❌ Invalid retention policy: %v", err)
+		}
+		if !retention.enabled() {
+			logger.Fatalf("❌ No retention rules given, use --keep-last, --keep-daily, --keep-weekly or --keep-monthly")
+		}
+
+		if err := pruneBackups(localSavePath, pruneDBName, retention, pruneDryRun); err != nil {
+			logger.Fatalf("❌ Backup pruning failed: %v", err)
+		}
+
+		logger.Info("✅ Backup pruning completed successfully")
+	},
+}
+
+// retentionPolicy describes which backups to keep; a value of zero disables a rule
+type retentionPolicy struct {
+	keepLast    int
+	keepDaily   int
+	keepWeekly  int
+	keepMonthly int
+}
+
+// backupEntry is a backup file found in a backup directory
+type backupEntry struct {
+	path   string
+	dbName string
+	// name groups the backups a retention policy applies to, the job name or
+	// the database name their files start with
+	name      string
+	timestamp time.Time
+	// size is the size of the backup file, or -1 if the file of a manifest is missing
+	size int64
+	// manifest is nil for backups written before manifests were introduced
+	manifest *backupManifest
+	// partial is set for the temporary file of a backup that is still being
+	// written or was abandoned by a run that crashed
+	partial bool
+}
+
+var (
+	retention   retentionPolicy
+	pruneDBName string
+	pruneDryRun bool
+)
+
+// backupFilePattern matches the names of backups without a manifest: the
+// original .sql name of custom-format dumps as well as every later extension
+var backupFilePattern = regexp.MustCompile(`^(.+)_backup_(\d{8}_\d{6})(\.[A-Za-z0-9]+)+$`)
+
+func init() {
+	backupCmd.AddCommand(pruneCmd)
+	addRetentionFlags(pruneCmd, &retention)
+	pruneCmd.Flags().StringVar(&pruneDBName, "db", "", "Only prune backups named after this job or database (default: every name found)")
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "Show what would be deleted without deleting anything")
+}
+
+// addRetentionFlags registers the --keep-* flags on a command
+func addRetentionFlags(cmd *cobra.Command, policy *retentionPolicy) {
+	cmd.Flags().IntVar(&policy.keepLast, "keep-last", 0, "Keep the N most recent backups")
+	cmd.Flags().IntVar(&policy.keepDaily, "keep-daily", 0, "Keep the newest backup of each of the last N days with backups")
+	cmd.Flags().IntVar(&policy.keepWeekly, "keep-weekly", 0, "Keep the newest backup of each of the last N weeks with backups")
+	cmd.Flags().IntVar(&policy.keepMonthly, "keep-monthly", 0, "Keep the newest backup of each of the last N months with backups")
+}
+
+// enabled reports whether at least one retention rule is set
+func (p retentionPolicy) enabled() bool {
+	return p.keepLast > 0 || p.keepDaily > 0 || p.keepWeekly > 0 || p.keepMonthly > 0
+}
+
+// validate rejects negative rule values
+func (p retentionPolicy) validate() error {
+	if p.keepLast < 0 || p.keepDaily < 0 || p.keepWeekly < 0 || p.keepMonthly < 0 {
+		return fmt.Errorf("--keep-* values must not be negative")
+	}
+	return nil
+}
+
+// apply splits backups of a single database into those to keep, with the rules that keep them, and those to delete
+func (p retentionPolicy) apply(backups []backupEntry) (keep map[string][]string, remove []backupEntry) {
+	sorted := append([]backupEntry{}, backups...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].timestamp.After(sorted[j].timestamp) })
+
+	keep = map[string][]string{}
+	for i, b := range sorted {
+		if i < p.keepLast {
+			keep[b.path] = append(keep[b.path], "last")
+		}
+	}
+
+	keepNewestPerPeriod := func(rule string, limit int, period func(time.Time) string) {
+		seen := map[string]bool{}
+		for _, b := range sorted {
+			if len(seen) >= limit {
+				return
+			}
+			key := period(b.timestamp)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			keep[b.path] = append(keep[b.path], rule)
+		}
+	}
+	keepNewestPerPeriod("daily", p.keepDaily, func(t time.Time) string { return t.Format("2006-01-02") })
+	keepNewestPerPeriod("weekly", p.keepWeekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+	keepNewestPerPeriod("monthly", p.keepMonthly, func(t time.Time) string { return t.Format("2006-01") })
+
+	for _, b := range sorted {
+		if _, ok := keep[b.path]; !ok {
+			remove = append(remove, b)
+		}
+	}
+	return keep, remove
+}
+
+// storedFile is a file found in a backup directory or under an S3 prefix
+type storedFile struct {
+	name string
+	size int64
+}
+
+// listBackups finds the backups in dir, optionally restricted to one database
+func listBackups(dir, dbName string) ([]backupEntry, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory %s: %w", dir, err)
+	}
+
+	var files []storedFile
+	for _, entry := range entries {
+		var size int64
+		if entry.IsDir() {
+			// Directory-format dumps are directories of files
+			if size, err = dirSize(filepath.Join(dir, entry.Name())); err != nil {
+				continue
+			}
+		} else {
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			size = info.Size()
+		}
+		files = append(files, storedFile{name: entry.Name(), size: size})
+	}
+
+	return collectBackups(files, dbName,
+		func(name string) string { return filepath.Join(dir, name) },
+		func(name string) (*backupManifest, error) { return readManifest(filepath.Join(dir, name)) },
+	), nil
+}
+
+// collectBackups recognises the backups among the files of one location,
+// optionally restricted to one database or backup name. Backups are described
+// by their manifests; files without a manifest are recognised by the
+// <name>_backup_<timestamp> naming scheme. location maps a file name to its path
+// and loadManifest reads a manifest by file name.
+func collectBackups(files []storedFile, dbName string, location func(name string) string, loadManifest func(name string) (*backupManifest, error)) []backupEntry {
+	sizes := map[string]int64{}
+	for _, file := range files {
+		sizes[file.name] = file.size
+	}
+
+	var backups []backupEntry
+	for _, file := range files {
+		var backup backupEntry
+		if strings.HasSuffix(file.name, manifestSuffix) {
+			manifest, err := loadManifest(file.name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "❌ Skipping %v\n", err)
+				continue
+			}
+			size, ok := sizes[manifest.File]
+			if !ok {
+				size = -1
+			}
+			backup = backupEntry{
+				path:      location(manifest.File),
+				dbName:    manifest.Database,
+				name:      manifest.Name,
+				timestamp: manifest.StartedAt,
+				size:      size,
+				manifest:  manifest,
+			}
+		} else {
+			if _, ok := sizes[file.name+manifestSuffix]; ok {
+				continue
+			}
+			name, partial := partialBackupName(file.name)
+			if !partial {
+				name = file.name
+			} else if strings.HasSuffix(name, manifestSuffix) {
+				// The temporary file a manifest is written to before it is renamed
+				continue
+			}
+			match := backupFilePattern.FindStringSubmatch(name)
+			if match == nil {
+				continue
+			}
+			timestamp, err := time.ParseInLocation("20060102_150405", match[2], time.Local)
+			if err != nil {
+				continue
+			}
+			backup = backupEntry{
+				path:      location(file.name),
+				dbName:    match[1],
+				name:      match[1],
+				timestamp: timestamp,
+				size:      file.size,
+				partial:   partial,
+			}
+		}
+
+		if backup.name == "" {
+			// Manifests written before backups were named after their job
+			backup.name = backup.dbName
+		}
+		if dbName != "" && backup.dbName != dbName && backup.name != dbName {
+			continue
+		}
+		backups = append(backups, backup)
+	}
+	return backups
+}
+
+// removeBackup deletes a backup file or directory dump together with its manifest
+func removeBackup(b backupEntry) error {
+	if err := os.RemoveAll(b.path); err != nil {
+		return err
+	}
+	if err := os.Remove(manifestPath(b.path)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// pruneBackups applies the retention policy separately to the backups of every
+// name, a job or database, found in dir, or only to those of name when it is
+// set, deleting the backups that are not kept
+func pruneBackups(dir, name string, policy retentionPolicy, dryRun bool) error {
+	backups, err := listBackups(dir, name)
+	if err != nil {
+		return err
+	}
+
+	byDB := map[string][]backupEntry{}
+	var dbNames []string
+	for _, b := range backups {
+		// Partial files may belong to a running backup, db backup list shows them
+		if b.partial || (name != "" && b.name != name) {
+			continue
+		}
+		if _, ok := byDB[b.name]; !ok {
+			dbNames = append(dbNames, b.name)
+		}
+		byDB[b.name] = append(byDB[b.name], b)
+	}
+	sort.Strings(dbNames)
+
+	var failed []string
+	for _, name := range dbNames {
+		keep, remove := policy.apply(byDB[name])
+		fmt.Printf("📦 %s: %d backups, keeping %d, removing %d\n", name, len(byDB[name]), len(keep), len(remove))
+
+		for _, b := range byDB[name] {
+			if rules, ok := keep[b.path]; ok {
+				fmt.Printf("   keep    %s (%s)\n", filepath.Base(b.path), strings.Join(rules, ", "))
+			}
+		}
+		for _, b := range remove {
+			if dryRun {
+				fmt.Printf("   would remove %s\n", filepath.Base(b.path))
+				continue
+			}
+			if err := removeBackup(b); err != nil {
+				fmt.Printf("❌ Failed to remove %s: %v\n", b.path, err)
+				failed = append(failed, b.path)
+				continue
+			}
+			fmt.Printf("   removed %s\n", filepath.Base(b.path))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to remove %d backups", len(failed))
+	}
+	return nil
+}
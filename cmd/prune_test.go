@@ -0,0 +1,301 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeBackupFiles creates empty files with the given names in a new temporary directory
+func writeBackupFiles(t *testing.T, names ...string) string {
+	dir := t.TempDir()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// dirFileNames returns the sorted names of the files left in dir
+func dirFileNames(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRetentionPolicyApply(t *testing.T) {
+	// Newest first; 2024-03-15 is in ISO week 11 and 2023-12-31 in week 52 of 2023
+	dir := writeBackupFiles(t,
+		"golang_backup_20240315_230000.sql", // a: Fri, week 11, March
+		"golang_backup_20240315_080000.sql", // b: same day as a
+		"golang_backup_20240314_120000.sql", // c: Thu, week 11
+		"golang_backup_20240310_120000.sql", // d: Sun, week 10
+		"golang_backup_20240304_120000.sql", // e: Mon, week 10
+		"golang_backup_20240229_120000.sql", // f: Thu, week 9, February
+		"golang_backup_20240131_120000.sql", // g: Wed, week 5, January
+		"golang_backup_20231231_120000.sql", // h: Sun, 2023 week 52, December
+	)
+	backups, err := listBackups(dir, "golang")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 8 {
+		t.Fatalf("listBackups found %d backups, want 8", len(backups))
+	}
+
+	tests := []struct {
+		name       string
+		policy     retentionPolicy
+		wantKeep   map[string][]string
+		wantRemove []string
+	}{
+		{
+			name:   "keep last",
+			policy: retentionPolicy{keepLast: 2},
+			wantKeep: map[string][]string{
+				"20240315_230000": {"last"},
+				"20240315_080000": {"last"},
+			},
+			wantRemove: []string{"20240314_120000", "20240310_120000", "20240304_120000", "20240229_120000", "20240131_120000", "20231231_120000"},
+		},
+		{
+			name:   "keep last more than there are",
+			policy: retentionPolicy{keepLast: 10},
+			wantKeep: map[string][]string{
+				"20240315_230000": {"last"},
+				"20240315_080000": {"last"},
+				"20240314_120000": {"last"},
+				"20240310_120000": {"last"},
+				"20240304_120000": {"last"},
+				"20240229_120000": {"last"},
+				"20240131_120000": {"last"},
+				"20231231_120000": {"last"},
+			},
+		},
+		{
+			name:   "keep daily keeps the newest backup of each day",
+			policy: retentionPolicy{keepDaily: 3},
+			wantKeep: map[string][]string{
+				"20240315_230000": {"daily"},
+				"20240314_120000": {"daily"},
+				"20240310_120000": {"daily"},
+			},
+			wantRemove: []string{"20240315_080000", "20240304_120000", "20240229_120000", "20240131_120000", "20231231_120000"},
+		},
+		{
+			name:   "keep weekly counts ISO weeks",
+			policy: retentionPolicy{keepWeekly: 3},
+			wantKeep: map[string][]string{
+				"20240315_230000": {"weekly"},
+				"20240310_120000": {"weekly"},
+				"20240229_120000": {"weekly"},
+			},
+			wantRemove: []string{"20240315_080000", "20240314_120000", "20240304_120000", "20240131_120000", "20231231_120000"},
+		},
+		{
+			name:   "keep monthly crosses the year",
+			policy: retentionPolicy{keepMonthly: 4},
+			wantKeep: map[string][]string{
+				"20240315_230000": {"monthly"},
+				"20240229_120000": {"monthly"},
+				"20240131_120000": {"monthly"},
+				"20231231_120000": {"monthly"},
+			},
+			wantRemove: []string{"20240315_080000", "20240314_120000", "20240310_120000", "20240304_120000"},
+		},
+		{
+			name:   "grandfather-father-son",
+			policy: retentionPolicy{keepLast: 1, keepDaily: 2, keepWeekly: 3, keepMonthly: 4},
+			wantKeep: map[string][]string{
+				"20240315_230000": {"last", "daily", "weekly", "monthly"},
+				"20240314_120000": {"daily"},
+				"20240310_120000": {"weekly"},
+				"20240229_120000": {"weekly", "monthly"},
+				"20240131_120000": {"monthly"},
+				"20231231_120000": {"monthly"},
+			},
+			wantRemove: []string{"20240315_080000", "20240304_120000"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep, remove := tt.policy.apply(backups)
+
+			gotKeep := map[string][]string{}
+			for path, rules := range keep {
+				gotKeep[backupTimestamp(path)] = rules
+			}
+			if !reflect.DeepEqual(gotKeep, tt.wantKeep) {
+				t.Errorf("apply kept %v, want %v", gotKeep, tt.wantKeep)
+			}
+
+			var gotRemove []string
+			for _, b := range remove {
+				gotRemove = append(gotRemove, backupTimestamp(b.path))
+			}
+			if !reflect.DeepEqual(gotRemove, tt.wantRemove) {
+				t.Errorf("apply removed %v, want %v", gotRemove, tt.wantRemove)
+			}
+		})
+	}
+}
+
+// backupTimestamp returns the timestamp part of a backup file name
+func backupTimestamp(path string) string {
+	return backupFilePattern.FindStringSubmatch(filepath.Base(path))[2]
+}
+
+func TestCollectBackups(t *testing.T) {
+	files := []storedFile{
+		{name: "golang_backup_20240301_000000.sql", size: 10},
+		{name: "golang_backup_20240302_000000.sql.gz.partial-123", size: 20},
+		{name: "nightly_backup_20240301_000000.dump", size: 30},
+		{name: "nightly_backup_20240301_000000.dump.json"},
+		{name: "nightly_backup_20240302_000000.dump.json"},
+		{name: "nightly_backup_20240303_000000.dump.partial-456", size: 40},
+		{name: "nightly_backup_20240303_000000.dump.json.partial-789"},
+		{name: "golang_backup_20240303_000000.sql.json.partial-012"},
+		{name: "golang_backup_notes.txt"},
+	}
+	manifests := map[string]*backupManifest{
+		"nightly_backup_20240301_000000.dump.json": {File: "nightly_backup_20240301_000000.dump", Name: "nightly", Database: "golang"},
+		"nightly_backup_20240302_000000.dump.json": {File: "nightly_backup_20240302_000000.dump", Database: "golang"},
+	}
+	backups := collectBackups(files, "",
+		func(name string) string { return "dir/" + name },
+		func(name string) (*backupManifest, error) { return manifests[name], nil },
+	)
+
+	type entry struct {
+		path    string
+		dbName  string
+		name    string
+		size    int64
+		partial bool
+	}
+	var got []entry
+	for _, b := range backups {
+		got = append(got, entry{b.path, b.dbName, b.name, b.size, b.partial})
+	}
+	want := []entry{
+		{"dir/golang_backup_20240301_000000.sql", "golang", "golang", 10, false},
+		{"dir/golang_backup_20240302_000000.sql.gz.partial-123", "golang", "golang", 20, true},
+		{"dir/nightly_backup_20240301_000000.dump", "golang", "nightly", 30, false},
+		{"dir/nightly_backup_20240302_000000.dump", "golang", "golang", -1, false},
+		{"dir/nightly_backup_20240303_000000.dump.partial-456", "nightly", "nightly", 40, true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("collectBackups = %+v, want %+v", got, want)
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	files := []string{
+		"db1_backup_20240301_000000.sql",
+		"db1_backup_20240302_000000.sql",
+		"db1_backup_20240303_000000.sql",
+		"db1_backup_20240304_000000.sql.partial-123",
+		"db2_backup_20240301_000000.sql.gz",
+		"db2_backup_20240302_000000.sql.gz",
+		"nightly_backup_20240301_000000.dump",
+		"nightly_backup_20240302_000000.dump",
+		"nightly_backup_20240302_000000.dump.json.partial-456",
+		"notes.txt",
+	}
+	writeManifests := func(t *testing.T, dir string) {
+		for _, file := range []string{"nightly_backup_20240301_000000.dump", "nightly_backup_20240302_000000.dump"} {
+			startedAt, _ := time.ParseInLocation("20060102_150405", backupTimestamp(file), time.Local)
+			manifest := &backupManifest{File: file, Name: "nightly", Database: "db1", StartedAt: startedAt}
+			if err := writeManifest(filepath.Join(dir, file), manifest); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter string
+		dryRun bool
+		want   []string
+	}{
+		{
+			name: "every name",
+			want: []string{
+				"db1_backup_20240303_000000.sql",
+				"db1_backup_20240304_000000.sql.partial-123",
+				"db2_backup_20240302_000000.sql.gz",
+				"nightly_backup_20240302_000000.dump",
+				"nightly_backup_20240302_000000.dump.json",
+				"nightly_backup_20240302_000000.dump.json.partial-456",
+				"notes.txt",
+			},
+		},
+		{
+			name:   "one database leaves the job backups of it alone",
+			filter: "db1",
+			want: []string{
+				"db1_backup_20240303_000000.sql",
+				"db1_backup_20240304_000000.sql.partial-123",
+				"db2_backup_20240301_000000.sql.gz",
+				"db2_backup_20240302_000000.sql.gz",
+				"nightly_backup_20240301_000000.dump",
+				"nightly_backup_20240301_000000.dump.json",
+				"nightly_backup_20240302_000000.dump",
+				"nightly_backup_20240302_000000.dump.json",
+				"nightly_backup_20240302_000000.dump.json.partial-456",
+				"notes.txt",
+			},
+		},
+		{
+			name:   "one job",
+			filter: "nightly",
+			want: []string{
+				"db1_backup_20240301_000000.sql",
+				"db1_backup_20240302_000000.sql",
+				"db1_backup_20240303_000000.sql",
+				"db1_backup_20240304_000000.sql.partial-123",
+				"db2_backup_20240301_000000.sql.gz",
+				"db2_backup_20240302_000000.sql.gz",
+				"nightly_backup_20240302_000000.dump",
+				"nightly_backup_20240302_000000.dump.json",
+				"nightly_backup_20240302_000000.dump.json.partial-456",
+				"notes.txt",
+			},
+		},
+		{
+			name:   "dry run",
+			dryRun: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeBackupFiles(t, files...)
+			writeManifests(t, dir)
+			before := dirFileNames(t, dir)
+
+			if err := pruneBackups(dir, tt.filter, retentionPolicy{keepLast: 1}, tt.dryRun); err != nil {
+				t.Fatalf("pruneBackups failed: %v", err)
+			}
+
+			want := tt.want
+			if tt.dryRun {
+				want = before
+			}
+			if got := dirFileNames(t, dir); !reflect.DeepEqual(got, want) {
+				t.Errorf("pruneBackups left\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+		})
+	}
+}
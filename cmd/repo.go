@@ -1,9 +1,14 @@
 package cmd
 
 import (
+	"os"
+
 	"github.com/spf13/cobra"
 )
 
+// githubTokenFlag is the token handed to gh, a literal value or a secret reference
+var githubTokenFlag string
+
 // repoCmd represents the repo command
 var repoCmd = &cobra.Command{
 	Use:   "repo",
@@ -11,8 +16,31 @@ var repoCmd = &cobra.Command{
 	Long: `The "repo" command provides useful tools to quickly spin up and manage
 your GitHub repositories. It allows you to create new repositories, push
 local projects to GitHub, and perform other common GitHub operations.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if err := setGitHubToken(cmd); err != nil {
+			createCustomLogger().Fatalf("❌ Invalid GitHub token: %v", err)
+		}
+	},
 }
 
 func init() {
 	rootCmd.AddCommand(repoCmd)
+	repoCmd.PersistentFlags().StringVar(&githubTokenFlag, "github-token", "", "GitHub token for gh, e.g. secret://vault/<mount>/<path>#<key> or env://NAME (default $GH_TOKEN, which may also be a secret reference)")
+}
+
+// setGitHubToken resolves the --github-token flag, or a secret reference in
+// GH_TOKEN, and exports the token as GH_TOKEN for the gh commands run later
+func setGitHubToken(cmd *cobra.Command) error {
+	token := githubTokenFlag
+	if token == "" {
+		token = os.Getenv("GH_TOKEN")
+		if !isSecretRef(token) {
+			return nil
+		}
+	}
+	token, err := resolveSecret(cmd.Context(), token)
+	if err != nil {
+		return err
+	}
+	return os.Setenv("GH_TOKEN", token)
 }
@@ -0,0 +1,354 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"filippo.io/age"
+	"github.com/minio/minio-go/v7"
+	"github.com/spf13/cobra"
+)
+
+// restoreCmd represents the command to restore a database from a backup file
+var restoreCmd = &cobra.Command{
+	Use: "restore <db_config> <backup_file|s3://bucket/key>",
+	Short: `Restore a PostgreSQL, MySQL/MariaDB, SQLite or MongoDB database from a backup, locally or over SSH.
+	gzip, zstd and lz4 compressed backups are detected and decompressed automatically,
+	and encrypted backups are decrypted with --identity or --passphrase-file.
+	Plain-format PostgreSQL dumps are run with psql, directory dumps are read in place.
+	Progress is reported on stderr, see --progress.
+
+		db_config: <username>[:<password>]@<host>:<port>/<dbname>
+		    or postgres://<username>:<password>@<host>:<port>/<dbname>?sslmode=require
+		    or mysql://<username>:<password>@<host>:<port>/<dbname>
+		    or sqlite:///<path>
+		    or mongodb://<username>:<password>@<host>:<port>/<dbname>
+		    or "host=<host> port=<port> user=<username> password=<password> dbname=<dbname>"
+		Leave the password out to read it from --password-file, --password-env,
+		--password-secret, --password-prompt or, for PostgreSQL, ~/.pgpass.
+		db_config may also be a secret reference: env://<NAME>, file://<path>,
+		secret://vault/<mount>/<path>#<key> or secret://pass/<path>[#<key>].
+		e.g., postgres:v8hlDV0yMAHHlIurYupj@10.1.0.54:15432/golang
+
+		--remote: [<user>@]<host>[[:<ssh-port>]:<remote-db-port>]
+		e.g., --remote admin@192.168.1.10:5432 or --remote bastion:2222:5432
+		The host may be an alias from ~/.ssh/config`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		dbConfig := args[0]
+		backupFile := args[1]
+
+		ctx := cmd.Context()
+		logger := createCustomLogger()
+		logger.Info("🚀 Starting database restore process")
+
+		if err := checkProgressMode(progressFlag); err != nil {
+			logger.Fatalf("❌ Invalid --progress: %v", err)
+		}
+
+		if !isS3URL(backupFile) {
+			if _, err := os.Stat(backupFile); err != nil {
+				logger.Fatalf("❌ Backup file is not accessible: %v", err)
+			}
+		}
+		if restoreJobs < 1 {
+			logger.Fatalf("❌ Invalid --jobs value %d: must be at least 1", restoreJobs)
+		}
+
+		conn, err := resolveDBConfig(ctx, dbConfig)
+		if err != nil {
+			logger.Fatalf("❌ Invalid database configuration format: %v", err)
+		}
+		if err := passwordFlags.readPrompt(dbConfig); err != nil {
+			logger.Fatalf("❌ Invalid password settings: %v", err)
+		}
+		if err := passwordFlags.apply(ctx, conn); err != nil {
+			logger.Fatalf("❌ Invalid password settings: %v", err)
+		}
+
+		identities, err := decryptionIdentities(identityFileFlag, passphraseFileFlag)
+		if err != nil {
+			logger.Fatalf("❌ Invalid decryption settings: %v", err)
+		}
+
+		opts := restoreOptions{
+			create:     restoreCreate,
+			clean:      restoreClean,
+			schema:     restoreSchema,
+			table:      restoreTable,
+			jobs:       restoreJobs,
+			identities: identities,
+		}
+
+		if remoteFlag != "" {
+			remoteUser, remoteHost, remoteDBPort, parseErr := parseRemoteFlag(remoteFlag)
+			if parseErr != nil {
+				logger.Fatalf("❌ Invalid --remote format: %v", parseErr)
+			}
+			if remoteDBPort == "" {
+				remoteDBPort = conn.port
+			}
+			err = restoreDatabaseRemote(ctx, conn, localPortFlag, backupFile, remoteUser, remoteHost, remoteDBPort, opts)
+		} else {
+			err = restoreDatabaseLocal(ctx, conn, backupFile, opts)
+		}
+
+		if err != nil {
+			logger.Fatalf("❌ Database restore failed: %v", err)
+		}
+
+		logger.Info("✅ Database restore completed successfully")
+	},
+}
+
+// restoreOptions controls how a backup is applied to the target database
+type restoreOptions struct {
+	create bool
+	clean  bool
+	schema string
+	table  string
+	jobs   int
+	// identities decrypt encrypted backups
+	identities []age.Identity
+	// progress reports the restore's progress; nil when it is not reported
+	progress *progressReporter
+}
+
+var (
+	restoreCreate bool
+	restoreClean  bool
+	restoreSchema string
+	restoreTable  string
+	restoreJobs   int
+
+	identityFileFlag string
+)
+
+func init() {
+	dbCmd.AddCommand(restoreCmd)
+	restoreCmd.Flags().StringVar(&remoteFlag, "remote", "", "Specify remote connection in format [<user>@]<host>[[:<ssh_port>]:<db_port>] (default port: the one in db_config)")
+	restoreCmd.Flags().StringVar(&localPortFlag, "local-port", "", "Local port for the SSH tunnel (default: a free ephemeral port)")
+	restoreCmd.Flags().BoolVar(&restoreCreate, "create", false, "Create the target database before restoring into it")
+	restoreCmd.Flags().BoolVar(&restoreClean, "clean", false, "Drop existing database objects before recreating them (with --create, drop the whole database)")
+	restoreCmd.Flags().StringVar(&restoreSchema, "schema", "", "Restore only objects in this schema (PostgreSQL only)")
+	restoreCmd.Flags().StringVar(&restoreTable, "table", "", "Restore only this table (PostgreSQL only)")
+	restoreCmd.Flags().IntVarP(&restoreJobs, "jobs", "j", 1, "Number of parallel jobs used by pg_restore (PostgreSQL only)")
+	restoreCmd.Flags().StringVar(&identityFileFlag, "identity", "", "age identity file used to decrypt an encrypted backup")
+	restoreCmd.Flags().StringVar(&passphraseFileFlag, "passphrase-file", "", "File holding the passphrase of an encrypted backup")
+	addPasswordFlags(restoreCmd, &passwordFlags)
+	restoreCmd.Flags().StringVar(&progressFlag, "progress", "auto", "Progress reporting on stderr: auto, live, log, json or none")
+}
+
+// restoreDatabaseLocal restores the backup file into a directly reachable database
+func restoreDatabaseLocal(ctx context.Context, conn *dbConnection, backupFile string, opts restoreOptions) error {
+	if err := conn.engine.checkRestore(opts); err != nil {
+		return err
+	}
+	if opts.create {
+		if err := conn.engine.createDatabase(ctx, conn, opts.clean); err != nil {
+			return err
+		}
+	}
+	return restoreBackup(ctx, conn, backupFile, opts, func(archive io.Reader, info backupArchive, opts restoreOptions) error {
+		return conn.engine.restore(ctx, conn, backupFile, archive, info, opts)
+	})
+}
+
+// restoreDatabaseRemote restores the backup file into a database reachable only through an SSH tunnel,
+// or over an SSH session for file-based engines
+func restoreDatabaseRemote(ctx context.Context, conn *dbConnection, localPort, backupFile, remoteUser, remoteHost, remoteDBPort string, opts restoreOptions) error {
+	if engine, ok := conn.engine.(sshEngine); ok {
+		if err := conn.engine.checkRestore(opts); err != nil {
+			return err
+		}
+		return restoreBackup(ctx, conn, backupFile, opts, func(archive io.Reader, info backupArchive, opts restoreOptions) error {
+			return engine.restoreOverSSH(ctx, conn, remoteUser, remoteHost, archive, info, opts)
+		})
+	}
+
+	tunnel, err := startSSHTunnel(ctx, localPort, conn.remoteHost(), remoteUser, remoteHost, remoteDBPort)
+	if err != nil {
+		return err
+	}
+	defer tunnel.Close()
+
+	return restoreDatabaseLocal(ctx, conn.withAddress("127.0.0.1", tunnel.localPort()), backupFile, opts)
+}
+
+// restoreBackup decrypts and decompresses a local or S3-hosted backup on the fly
+// and hands it to apply, reporting the progress by the bytes read from the backup
+func restoreBackup(ctx context.Context, conn *dbConnection, backupFile string, opts restoreOptions, apply func(archive io.Reader, info backupArchive, opts restoreOptions) error) error {
+	if info, err := os.Stat(backupFile); err == nil && info.IsDir() {
+		return restoreDirectory(conn, backupFile, opts, apply)
+	}
+
+	source, err := openBackupSource(ctx, backupFile)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	opts.progress = newProgressReporter("restore", conn.dbName, backupSourceSize(source))
+	archive, info, err := openBackupArchive(io.TeeReader(source, opts.progress), opts.identities)
+	if err != nil {
+		opts.progress.finish(err)
+		return err
+	}
+	defer archive.Close()
+
+	err = apply(archive, info, opts)
+	opts.progress.finish(err)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Restored %s into database %s\n", backupFile, conn.dbName)
+	return nil
+}
+
+// restoreDirectory hands a directory dump to apply without an archive stream,
+// so the engine's restore tool reads the directory in place
+func restoreDirectory(conn *dbConnection, dir string, opts restoreOptions, apply func(archive io.Reader, info backupArchive, opts restoreOptions) error) error {
+	if _, ok := conn.engine.(directoryEngine); !ok {
+		return fmt.Errorf("%s is a directory, but %s backups are single files", dir, conn.engine.name())
+	}
+
+	opts.progress = newProgressReporter("restore", conn.dbName, 0)
+	opts.progress.untrack()
+	err := apply(nil, backupArchive{codec: compressionCodecs["none"]}, opts)
+	opts.progress.finish(err)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Restored %s into database %s\n", dir, conn.dbName)
+	return nil
+}
+
+// openBackupSource opens a local backup file or an s3:// object. Reads fail
+// once ctx is cancelled.
+func openBackupSource(ctx context.Context, backupFile string) (io.ReadCloser, error) {
+	if isS3URL(backupFile) {
+		return openS3Object(ctx, backupFile)
+	}
+	file, err := os.Open(backupFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup file: %w", err)
+	}
+	return &localSource{File: file, ctx: ctx}, nil
+}
+
+// localSource is a local backup file that stops reading once ctx is cancelled
+type localSource struct {
+	*os.File
+	ctx context.Context
+}
+
+func (s *localSource) Read(p []byte) (int, error) {
+	return contextReader{s.ctx, s.File}.Read(p)
+}
+
+// backupSourceSize returns the size of an opened backup source, or 0 if it cannot tell
+func backupSourceSize(source io.ReadCloser) int64 {
+	switch source := source.(type) {
+	case *localSource:
+		if info, err := source.Stat(); err == nil {
+			return info.Size()
+		}
+	case *minio.Object:
+		if info, err := source.Stat(); err == nil {
+			return info.Size
+		}
+	}
+	return 0
+}
+
+// decompressToTemp writes a decompressed local backup to a file in a private
+// temporary directory next to it and returns its path with a function that
+// removes the directory. Decrypted data never goes through it.
+func decompressToTemp(archive io.Reader, backupFile string) (string, func(), error) {
+	tempDir, err := os.MkdirTemp(filepath.Dir(backupFile), ".omti-unpack-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(tempDir) }
+
+	tempFile, err := os.OpenFile(filepath.Join(tempDir, "dump"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer tempFile.Close()
+
+	if _, err := io.Copy(tempFile, archive); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to unpack backup: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	return tempFile.Name(), cleanup, nil
+}
+
+// runDBTool runs one of the engine's client tools with the connection's password and parameters in its environment
+func runDBTool(ctx context.Context, name string, conn *dbConnection, args ...string) error {
+	return runDBToolWithInput(ctx, name, conn, nil, args...)
+}
+
+// runDBToolWithInput runs a client tool like runDBTool, feeding it stdin when it is not nil
+func runDBToolWithInput(ctx context.Context, name string, conn *dbConnection, stdin io.Reader, args ...string) error {
+	_, err := dbToolOutput(ctx, name, conn, stdin, args...)
+	return err
+}
+
+// runLoggedDBTool runs a client tool like runDBToolWithInput, reading its stderr through log when it is not nil
+func runLoggedDBTool(ctx context.Context, name string, conn *dbConnection, stdin io.Reader, log *toolLogFilter, args ...string) error {
+	_, err := execDBTool(ctx, name, conn, stdin, log, args...)
+	return err
+}
+
+// dbToolOutput runs a client tool like runDBToolWithInput and returns its output.
+// Tools that do not connect to a database may be run with a nil conn.
+func dbToolOutput(ctx context.Context, name string, conn *dbConnection, stdin io.Reader, args ...string) ([]byte, error) {
+	return execDBTool(ctx, name, conn, stdin, nil, args...)
+}
+
+// execDBTool runs a client tool and returns its output, keeping its stderr for
+// the error message or reading it through log when that is not nil. The tool
+// is stopped when ctx is cancelled.
+func execDBTool(ctx context.Context, name string, conn *dbConnection, stdin io.Reader, log *toolLogFilter, args ...string) ([]byte, error) {
+	toolCmd := commandContext(ctx, name, args...)
+	if conn != nil {
+		env, cleanup, err := conn.env()
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		toolCmd.Env = env
+	}
+	toolCmd.Stdin = stdin
+	var stdOut, stdErr bytes.Buffer
+	toolCmd.Stdout = &stdOut
+	toolCmd.Stderr = &stdErr
+	if log != nil {
+		log.out = &stdErr
+		toolCmd.Stderr = log
+	}
+
+	err := toolCmd.Run()
+	if log != nil {
+		log.flush()
+	}
+	if cause := interruption(ctx); cause != nil {
+		return nil, fmt.Errorf("%s stopped: %w", name, cause)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute %s: %w\nOutput: %s\nError: %s", name, err, stdOut.String(), stdErr.String())
+	}
+	return stdOut.Bytes(), nil
+}
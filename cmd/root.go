@@ -10,7 +10,10 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var logLevel string
+var (
+	logLevel string
+	cfgFile  string
+)
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -24,10 +27,13 @@ var rootCmd = &cobra.Command{
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// Commands run with a context that is cancelled on SIGINT or SIGTERM.
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, stop := signalContext()
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
 	if err != nil {
-		os.Exit(1)
+		os.Exit(exitCode(1))
 	}
 }
 
@@ -36,7 +42,7 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.omti.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.omti.yaml)")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3PartSize is the multipart upload part size used for backups
+const s3PartSize = 64 << 20
+
+// isS3URL reports whether location refers to object storage rather than a local path
+func isS3URL(location string) bool {
+	return strings.HasPrefix(location, "s3://")
+}
+
+// parseS3URL splits s3://bucket/key into its bucket and key
+func parseS3URL(location string) (bucket, key string, err error) {
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return "", "", fmt.Errorf("invalid S3 URL %q, expected s3://<bucket>/<prefix>", location)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// newS3Client connects to S3-compatible storage using the config file, with the
+// standard AWS_* environment variables taking precedence. Credentials may be
+// secret references.
+func newS3Client(ctx context.Context) (*minio.Client, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	settings := config.S3
+
+	for _, override := range []struct {
+		value *string
+		envs  []string
+	}{
+		{&settings.Endpoint, []string{"AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"}},
+		{&settings.Region, []string{"AWS_REGION", "AWS_DEFAULT_REGION"}},
+		{&settings.AccessKeyID, []string{"AWS_ACCESS_KEY_ID"}},
+		{&settings.SecretAccessKey, []string{"AWS_SECRET_ACCESS_KEY"}},
+		{&settings.SessionToken, []string{"AWS_SESSION_TOKEN"}},
+	} {
+		for _, env := range override.envs {
+			if value := os.Getenv(env); value != "" {
+				*override.value = value
+				break
+			}
+		}
+	}
+
+	for _, credential := range []*string{&settings.AccessKeyID, &settings.SecretAccessKey, &settings.SessionToken} {
+		value, err := resolveSecret(ctx, *credential)
+		if err != nil {
+			return nil, fmt.Errorf("S3 credentials: %w", err)
+		}
+		*credential = value
+	}
+
+	if settings.Endpoint == "" {
+		settings.Endpoint = "s3.amazonaws.com"
+	}
+	if settings.Region == "" {
+		settings.Region = "us-east-1"
+	}
+
+	endpoint, secure := settings.Endpoint, true
+	if u, err := url.Parse(settings.Endpoint); err == nil && u.Host != "" {
+		endpoint, secure = u.Host, u.Scheme != "http"
+	}
+
+	lookup := minio.BucketLookupAuto
+	if settings.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(settings.AccessKeyID, settings.SecretAccessKey, settings.SessionToken),
+		Secure:       secure,
+		Region:       settings.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client for %s: %w", settings.Endpoint, err)
+	}
+	return client, nil
+}
+
+// uploadBackup uploads a backup file and its manifest to the s3://bucket/prefix destination
+func uploadBackup(ctx context.Context, backupFile, destination string) error {
+	bucket, prefix, err := parseS3URL(destination)
+	if err != nil {
+		return err
+	}
+	client, err := newS3Client(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range []string{backupFile, manifestPath(backupFile)} {
+		key := path.Join(prefix, filepath.Base(file))
+		info, err := client.FPutObject(ctx, bucket, key, file, minio.PutObjectOptions{
+			ContentType: "application/octet-stream",
+			PartSize:    s3PartSize,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upload %s to s3://%s/%s: %w", file, bucket, key, err)
+		}
+		fmt.Printf("✅ Uploaded %s to s3://%s/%s (%d bytes)\n", filepath.Base(file), bucket, key, info.Size)
+	}
+	return nil
+}
+
+// openS3Object opens an s3://bucket/key object for streaming reads
+func openS3Object(ctx context.Context, location string) (io.ReadCloser, error) {
+	bucket, key, err := parseS3URL(location)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newS3Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", location, err)
+	}
+	// GetObject is lazy, so check that the object exists before handing it out
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		return nil, fmt.Errorf("failed to open %s: %w", location, err)
+	}
+	return object, nil
+}
+
+// listS3Backups finds the backups stored under an s3://bucket/prefix location,
+// optionally restricted to one database
+func listS3Backups(ctx context.Context, location, dbName string) ([]backupEntry, error) {
+	bucket, prefix, err := parseS3URL(location)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newS3Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	var files []storedFile
+	for object := range client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", location, object.Err)
+		}
+		if strings.HasSuffix(object.Key, "/") {
+			continue
+		}
+		files = append(files, storedFile{name: strings.TrimPrefix(object.Key, prefix), size: object.Size})
+	}
+
+	return collectBackups(files, dbName,
+		func(name string) string { return fmt.Sprintf("s3://%s/%s%s", bucket, prefix, name) },
+		func(name string) (*backupManifest, error) {
+			key := prefix + name
+			object, err := client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("failed to read manifest s3://%s/%s: %w", bucket, key, err)
+			}
+			defer object.Close()
+			data, err := io.ReadAll(object)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read manifest s3://%s/%s: %w", bucket, key, err)
+			}
+			return decodeManifest(data, fmt.Sprintf("s3://%s/%s", bucket, key))
+		},
+	), nil
+}
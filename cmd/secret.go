@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Secret references stand in for a connection string, password or token and
+// are resolved when the value is read:
+//
+//	env://<NAME>                         an environment variable
+//	file://<path>                        the contents of a file
+//	secret://vault/<mount>/<path>#<key>  a key of a Vault KV v1 or v2 secret
+//	secret://pass/<path>[#<key>]         an entry of the pass password store
+var secretSchemes = []string{"env://", "file://", "secret://"}
+
+// secretProviders resolve secret://<provider>/<path>#<key> references
+var secretProviders = map[string]func(ctx context.Context, path, key string) (string, error){
+	"vault": readVaultSecret,
+	"pass":  readPassSecret,
+}
+
+// isSecretRef reports whether value is a secret reference rather than a literal
+func isSecretRef(value string) bool {
+	for _, scheme := range secretSchemes {
+		if strings.HasPrefix(value, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSecret returns the value a secret reference points to, or value
+// itself when it is not a reference
+func resolveSecret(ctx context.Context, value string) (string, error) {
+	if !isSecretRef(value) {
+		return value, nil
+	}
+	scheme, rest, _ := strings.Cut(value, "://")
+
+	var secret string
+	var err error
+	switch scheme {
+	case "env":
+		var ok bool
+		secret, ok = os.LookupEnv(rest)
+		if !ok {
+			err = fmt.Errorf("environment variable %s is not set", rest)
+		}
+	case "file":
+		var data []byte
+		data, err = os.ReadFile(rest)
+		secret = strings.TrimRight(string(data), "\r\n")
+	default:
+		ref, key, _ := strings.Cut(rest, "#")
+		provider, path, _ := strings.Cut(ref, "/")
+		read, ok := secretProviders[provider]
+		switch {
+		case !ok:
+			err = fmt.Errorf("unknown secret provider %q, expected vault or pass", provider)
+		case path == "":
+			err = fmt.Errorf("missing secret path")
+		default:
+			secret, err = read(ctx, path, key)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", value, err)
+	}
+	if secret == "" {
+		return "", fmt.Errorf("failed to resolve %s: the secret is empty", value)
+	}
+	return secret, nil
+}
+
+// resolveDBConfig resolves a db_config that may be a secret reference and parses it
+func resolveDBConfig(ctx context.Context, config string) (*dbConnection, error) {
+	config, err := resolveSecret(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	return parseDBConfig(config)
+}
+
+// vaultSettings returns the Vault address, token and namespace from the config
+// file, with VAULT_ADDR, VAULT_TOKEN and VAULT_NAMESPACE taking precedence and
+// ~/.vault-token, written by `vault login`, as the last resort for the token
+func vaultSettings() (vaultConfig, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return vaultConfig{}, err
+	}
+	settings := config.Vault
+	for _, override := range []struct {
+		value *string
+		env   string
+	}{
+		{&settings.Address, "VAULT_ADDR"},
+		{&settings.Token, "VAULT_TOKEN"},
+		{&settings.Namespace, "VAULT_NAMESPACE"},
+	} {
+		if value := os.Getenv(override.env); value != "" {
+			*override.value = value
+		}
+	}
+
+	if settings.Address == "" {
+		return vaultConfig{}, fmt.Errorf("no Vault address, set VAULT_ADDR or vault.address in the config file")
+	}
+	if settings.Token == "" {
+		data, err := os.ReadFile(filepath.Join(os.Getenv("HOME"), ".vault-token"))
+		if err != nil {
+			return vaultConfig{}, fmt.Errorf("no Vault token, set VAULT_TOKEN or vault.token in the config file")
+		}
+		settings.Token = strings.TrimSpace(string(data))
+	}
+	return settings, nil
+}
+
+// readVaultSecret reads a key of a KV secret. The first element of path is the
+// secrets engine mount, e.g. secret/db/golang reads db/golang from the engine
+// mounted at secret/. The key may be left out of single-key secrets.
+func readVaultSecret(ctx context.Context, path, key string) (string, error) {
+	settings, err := vaultSettings()
+	if err != nil {
+		return "", err
+	}
+	mount, name, ok := strings.Cut(path, "/")
+	if !ok || name == "" {
+		return "", fmt.Errorf("vault secret path %q must start with the mount, e.g. secret/db/golang", path)
+	}
+
+	var data map[string]any
+	if vaultKVVersion(ctx, settings, mount) == "1" {
+		var payload struct {
+			Data map[string]any `json:"data"`
+		}
+		err = vaultRequest(ctx, settings, mount+"/"+name, &payload)
+		data = payload.Data
+	} else {
+		var payload struct {
+			Data struct {
+				Data map[string]any `json:"data"`
+			} `json:"data"`
+		}
+		err = vaultRequest(ctx, settings, mount+"/data/"+name, &payload)
+		data = payload.Data.Data
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if key == "" {
+		if len(data) != 1 {
+			return "", fmt.Errorf("secret has %d keys, name one with #<key>", len(data))
+		}
+		for only := range data {
+			key = only
+		}
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("secret has no key %q", key)
+	}
+	secret, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("key %q of the secret is not a string", key)
+	}
+	return secret, nil
+}
+
+// vaultKVVersion returns the version of the KV secrets engine at mount, "1" or
+// "2", as the vault CLI looks it up. Tokens that may not read the mount's
+// settings get "" and are treated as version 2, the default of current Vaults.
+func vaultKVVersion(ctx context.Context, settings vaultConfig, mount string) string {
+	var payload struct {
+		Data struct {
+			Options struct {
+				Version string `json:"version"`
+			} `json:"options"`
+		} `json:"data"`
+	}
+	if err := vaultRequest(ctx, settings, "sys/internal/ui/mounts/"+mount, &payload); err != nil {
+		return ""
+	}
+	return payload.Data.Options.Version
+}
+
+// vaultRequest reads a Vault API path, e.g. secret/data/db/golang, and decodes
+// the response into out
+func vaultRequest(ctx context.Context, settings vaultConfig, path string, out any) error {
+	endpoint, err := url.JoinPath(settings.Address, "v1", path)
+	if err != nil {
+		return fmt.Errorf("invalid Vault address %q: %w", settings.Address, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", settings.Token)
+	if settings.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", settings.Namespace)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if cause := interruption(ctx); cause != nil {
+			return cause
+		}
+		return fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read Vault response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(body, &failure) == nil && len(failure.Errors) > 0 {
+			return fmt.Errorf("vault returned %s: %s", resp.Status, strings.Join(failure.Errors, ", "))
+		}
+		return fmt.Errorf("vault returned %s", resp.Status)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse Vault response: %w", err)
+	}
+	return nil
+}
+
+// readPassSecret reads an entry of the pass password store with `pass show`.
+// Without a key it returns the first line, the password; with one it returns
+// the value of the first "<key>: <value>" line after it.
+func readPassSecret(ctx context.Context, path, key string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := commandContext(ctx, "pass", "show", path)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if cause := interruption(ctx); cause != nil {
+			return "", fmt.Errorf("pass stopped: %w", cause)
+		}
+		return "", fmt.Errorf("pass show failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	lines := strings.Split(strings.ReplaceAll(stdout.String(), "\r\n", "\n"), "\n")
+	if key == "" {
+		return lines[0], nil
+	}
+	for _, line := range lines[1:] {
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), key) {
+			return strings.TrimSpace(value), nil
+		}
+	}
+	return "", fmt.Errorf("pass entry %s has no %q line", path, key)
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeVault serves a KV v1 engine at kv/, KV v2 engines at secret/ and at
+// locked/, whose settings the token may not read, and a failing secret
+func fakeVault(t *testing.T) *httptest.Server {
+	responses := map[string]string{
+		"/v1/sys/internal/ui/mounts/kv":     `{"data": {"path": "kv/", "type": "kv", "options": {"version": "1"}}}`,
+		"/v1/sys/internal/ui/mounts/secret": `{"data": {"path": "secret/", "type": "kv", "options": {"version": "2"}}}`,
+		"/v1/kv/db/golang":                  `{"data": {"password": "v1-secret"}}`,
+		"/v1/secret/data/db/golang":         `{"data": {"data": {"password": "v2-secret", "user": "app"}, "metadata": {"version": 3}}}`,
+		"/v1/secret/data/single":            `{"data": {"data": {"token": "single-secret"}}}`,
+		"/v1/secret/data/number":            `{"data": {"data": {"port": 5432}}}`,
+		"/v1/locked/data/app":               `{"data": {"data": {"password": "locked-secret"}}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("X-Vault-Token") != "test-token", r.URL.Path == "/v1/sys/internal/ui/mounts/locked":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"errors": ["1 error occurred:\n\t* permission denied\n\n"]}`)
+		case r.URL.Path == "/v1/secret/data/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"errors": ["internal error"]}`)
+		case r.URL.Path == "/v1/secret/data/html":
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprint(w, `<html>bad gateway</html>`)
+		case responses[r.URL.Path] != "":
+			fmt.Fprint(w, responses[r.URL.Path])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors": []}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestReadVaultSecret(t *testing.T) {
+	server := fakeVault(t)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "test-token")
+	t.Setenv("VAULT_NAMESPACE", "")
+
+	tests := []struct {
+		name    string
+		path    string
+		key     string
+		want    string
+		wantErr string
+	}{
+		{name: "kv v1", path: "kv/db/golang", key: "password", want: "v1-secret"},
+		{name: "kv v1 single key", path: "kv/db/golang", want: "v1-secret"},
+		{name: "kv v2", path: "secret/db/golang", key: "user", want: "app"},
+		{name: "kv v2 single key", path: "secret/single", want: "single-secret"},
+		{name: "unreadable mount settings default to v2", path: "locked/app", key: "password", want: "locked-secret"},
+		{name: "missing key", path: "secret/db/golang", key: "nope", wantErr: `secret has no key "nope"`},
+		{name: "key left out of a multi-key secret", path: "secret/db/golang", wantErr: "secret has 2 keys"},
+		{name: "key that is not a string", path: "secret/number", key: "port", wantErr: `key "port" of the secret is not a string`},
+		{name: "missing secret", path: "secret/db/missing", key: "password", wantErr: "vault returned 404 Not Found"},
+		{name: "missing kv v1 secret", path: "kv/db/missing", key: "password", wantErr: "vault returned 404 Not Found"},
+		{name: "server error", path: "secret/broken", wantErr: "vault returned 500 Internal Server Error: internal error"},
+		{name: "non-JSON error", path: "secret/html", wantErr: "vault returned 502 Bad Gateway"},
+		{name: "path without mount", path: "golang", wantErr: "must start with the mount"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readVaultSecret(context.Background(), tt.path, tt.key)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("readVaultSecret(%q, %q) error = %v, want it to contain %q", tt.path, tt.key, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readVaultSecret(%q, %q) failed: %v", tt.path, tt.key, err)
+			}
+			if got != tt.want {
+				t.Errorf("readVaultSecret(%q, %q) = %q, want %q", tt.path, tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadVaultSecretPermissionDenied(t *testing.T) {
+	server := fakeVault(t)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "wrong-token")
+
+	_, err := resolveSecret(context.Background(), "secret://vault/secret/db/golang#password")
+	if err == nil || !strings.Contains(err.Error(), "vault returned 403 Forbidden") || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("resolveSecret with a wrong token error = %v, want a 403 naming the permission error", err)
+	}
+}
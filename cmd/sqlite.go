@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// sqliteEngine backs up SQLite database files with the sqlite3 .dump command,
+// which reads the whole database in one transaction, so the dump is consistent
+// while other connections keep using the database
+type sqliteEngine struct{}
+
+// sqliteDumpHeader starts every dump written by .dump, and sqliteDumpTrailer
+// ends every complete one
+var (
+	sqliteDumpHeader  = []byte("PRAGMA foreign_keys=OFF;\nBEGIN TRANSACTION;\n")
+	sqliteDumpTrailer = []byte("COMMIT;")
+)
+
+func (sqliteEngine) name() string                 { return "sqlite" }
+func (sqliteEngine) defaultPort() string          { return "" }
+func (sqliteEngine) dumpExt(format string) string { return ".sql" }
+
+func (sqliteEngine) checkParam(key string) error {
+	return fmt.Errorf("unsupported connection parameter %q", key)
+}
+
+func (sqliteEngine) env(conn *dbConnection) ([]string, func(), error) {
+	return os.Environ(), func() {}, nil
+}
+
+// parseURI parses sqlite:///absolute/path/app.db or sqlite://relative/path/app.db.
+// The file name without its extension names the backups.
+func (sqliteEngine) parseURI(config string) (*dbConnection, error) {
+	u, err := url.Parse(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection URI: %w", err)
+	}
+
+	file := u.Host + u.Path
+	if file == "" || strings.HasSuffix(file, "/") {
+		return nil, fmt.Errorf("missing database file path")
+	}
+
+	conn := &dbConnection{
+		path:   file,
+		dbName: strings.TrimSuffix(path.Base(file), path.Ext(file)),
+		params: map[string]string{},
+	}
+	for key, values := range u.Query() {
+		conn.params[key] = values[len(values)-1]
+	}
+	return conn, nil
+}
+
+// checkDump rejects dump formats and object filters, a dump always holds the
+// whole database
+func (sqliteEngine) checkDump(opts dumpOptions) error {
+	switch {
+	case opts.format != "" || opts.jobs > 1:
+		return fmt.Errorf("--format and --jobs are only supported for PostgreSQL backups")
+	case !opts.filters.empty():
+		return fmt.Errorf("object filters are not supported for SQLite backups")
+	}
+	return nil
+}
+
+// dump streams the SQL text of the database from sqlite3, so the dump never
+// touches the disk before the backup pipeline compresses and encrypts it
+func (sqliteEngine) dump(ctx context.Context, conn *dbConnection, opts dumpOptions) (io.ReadCloser, error) {
+	return startDumpCommand(ctx, conn, nil, "sqlite3", "-readonly", conn.path, ".dump")
+}
+
+// dumpOverSSH dumps the database on the remote host and streams the dump back
+func (sqliteEngine) dumpOverSSH(ctx context.Context, conn *dbConnection, remoteUser, remoteHost string) (io.ReadCloser, error) {
+	return startSSHCommand(ctx, remoteUser, remoteHost, "sqlite3", "sqlite3 -readonly "+shellQuote(conn.path)+" .dump", nil)
+}
+
+// describeDump records the version of the sqlite3 shell, the dump itself does not name it
+func (sqliteEngine) describeDump(ctx context.Context, head []byte, manifest *backupManifest) {
+	manifest.Format = "sql"
+	if version, err := commandContext(ctx, "sqlite3", "--version").Output(); err == nil {
+		if fields := strings.Fields(string(version)); len(fields) > 0 {
+			manifest.DumpVersion = fields[0]
+		}
+	}
+}
+
+// checkRestore rejects the pg_restore-only options
+func (sqliteEngine) checkRestore(opts restoreOptions) error {
+	switch {
+	case opts.jobs > 1:
+		return fmt.Errorf("--jobs is not supported for SQLite restores")
+	case opts.schema != "":
+		return fmt.Errorf("--schema is not supported for SQLite restores")
+	case opts.table != "":
+		return fmt.Errorf("--table is not supported for SQLite restores")
+	}
+	return nil
+}
+
+// createDatabase has nothing to do, restoring writes a new database file
+func (sqliteEngine) createDatabase(ctx context.Context, conn *dbConnection, dropExisting bool) error {
+	return nil
+}
+
+// restore loads the dump into a new database file next to the target, checks it
+// and moves it into place. An existing database is only replaced with --clean.
+func (sqliteEngine) restore(ctx context.Context, conn *dbConnection, backupFile string, archive io.Reader, info backupArchive, opts restoreOptions) error {
+	if _, err := os.Stat(conn.path); err == nil && !opts.clean {
+		return fmt.Errorf("%s already exists, pass --clean to replace it", conn.path)
+	}
+
+	fmt.Printf("📦 Loading %s into %s\n", info, conn.path)
+	tempFile, err := os.CreateTemp(filepath.Dir(conn.path), filepath.Base(conn.path)+".omti-restore-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	if err := runDBToolWithInput(ctx, "sqlite3", conn, archive, "-bail", tempFile.Name()); err != nil {
+		return err
+	}
+
+	output, err := commandContext(ctx, "sqlite3", "-readonly", tempFile.Name(), "PRAGMA quick_check").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to check restored database: %w\nError: %s", err, output)
+	}
+	if check := strings.TrimSpace(string(output)); check != "ok" {
+		return fmt.Errorf("restored database failed the integrity check: %s", check)
+	}
+
+	// A write-ahead log left by the old database would be replayed into the new one
+	os.Remove(conn.path + "-wal")
+	os.Remove(conn.path + "-shm")
+	if err := os.Rename(tempFile.Name(), conn.path); err != nil {
+		return fmt.Errorf("failed to move restored database into place: %w", err)
+	}
+	return nil
+}
+
+// restoreOverSSH streams the dump to the remote host and loads it like restore
+func (sqliteEngine) restoreOverSSH(ctx context.Context, conn *dbConnection, remoteUser, remoteHost string, archive io.Reader, info backupArchive, opts restoreOptions) error {
+	clean := "0"
+	if opts.clean {
+		clean = "1"
+	}
+	script := fmt.Sprintf(`target=%s
+if [ -e "$target" ] && [ %s != 1 ]; then echo "$target already exists, pass --clean to replace it" >&2; exit 1; fi
+tmp="$target.omti-restore.$$"
+trap 'rm -f "$tmp"' EXIT
+sqlite3 -bail "$tmp" || exit 1
+check=$(sqlite3 -readonly "$tmp" "PRAGMA quick_check") || exit 1
+if [ "$check" != ok ]; then echo "restored database failed the integrity check: $check" >&2; exit 1; fi
+rm -f "$target-wal" "$target-shm" && mv "$tmp" "$target"`, shellQuote(conn.path), clean)
+
+	fmt.Printf("📦 Streaming %s to %s:%s\n", info, remoteHost, conn.path)
+	return runSSHCommand(ctx, remoteUser, remoteHost, "sqlite3 restore", script, archive)
+}
+
+// dropDatabase removes the database file together with its write-ahead log
+func (sqliteEngine) dropDatabase(ctx context.Context, conn *dbConnection) error {
+	for _, file := range []string{conn.path, conn.path + "-wal", conn.path + "-shm"} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// verifyArchive loads the dump into an in-memory database and runs PRAGMA
+// integrity_check on it, so the plain data is never written to disk. A dump
+// cut off between two statements loads cleanly, so it must also end with the
+// COMMIT that .dump writes last.
+func (sqliteEngine) verifyArchive(ctx context.Context, archive io.Reader) (string, error) {
+	tail := &dumpTail{n: 64}
+	checkCmd := commandContext(ctx, "sqlite3", "-bail", ":memory:")
+	checkCmd.Stdin = io.MultiReader(io.TeeReader(archive, tail), strings.NewReader("\nPRAGMA integrity_check;\n"))
+	output, err := checkCmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to load dump: %w\nError: %s", err, output)
+	}
+	if !bytes.HasSuffix(bytes.TrimSpace(tail.tail), sqliteDumpTrailer) {
+		return "", fmt.Errorf("dump does not end with %q, it may be truncated", sqliteDumpTrailer)
+	}
+	if check := strings.TrimSpace(string(output)); check != "ok" {
+		return "", fmt.Errorf("dump failed the integrity check: %s", check)
+	}
+	return fmt.Sprintf("complete SQL dump of %s, PRAGMA integrity_check ok", formatBytes(tail.size)), nil
+}
+
+// dumpTail keeps the last n bytes written to it and counts them all
+type dumpTail struct {
+	n    int
+	tail []byte
+	size int64
+}
+
+func (t *dumpTail) Write(p []byte) (int, error) {
+	t.size += int64(len(p))
+	t.tail = append(t.tail, p...)
+	if len(t.tail) > t.n {
+		t.tail = append([]byte{}, t.tail[len(t.tail)-t.n:]...)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// sshHost is how to reach an SSH host, with the settings of ~/.ssh/config applied
+type sshHost struct {
+	user     string
+	hostname string
+	port     string
+	// identityFiles replace the default keys in ~/.ssh when set
+	identityFiles []string
+	// proxyJump lists the [user@]host[:port] hops to connect through, in order
+	proxyJump []string
+}
+
+// address returns the host:port to connect to
+func (h *sshHost) address() string {
+	return net.JoinHostPort(h.hostname, h.port)
+}
+
+// resolveSSHHost applies the HostName, Port, User, IdentityFile and ProxyJump
+// settings of ~/.ssh/config and /etc/ssh/ssh_config to host, which may carry a
+// port. A user or port given explicitly wins over the config files, which win
+// over the local user name and port 22.
+func resolveSSHHost(remoteUser, host string) (*sshHost, error) {
+	port := ""
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		host, port = h, p
+	}
+	settings, err := readSSHConfig(host)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := &sshHost{user: remoteUser, hostname: host, port: port}
+	if resolved.user == "" {
+		resolved.user = settings.first("user")
+	}
+	if resolved.user == "" {
+		resolved.user = localUserName()
+	}
+	if hostname := settings.first("hostname"); hostname != "" {
+		resolved.hostname = strings.NewReplacer("%h", host, "%%", "%").Replace(hostname)
+	}
+	if resolved.port == "" {
+		resolved.port = settings.first("port")
+	}
+	if resolved.port == "" {
+		resolved.port = "22"
+	}
+
+	home := os.Getenv("HOME")
+	expand := strings.NewReplacer("%d", home, "%h", resolved.hostname, "%r", resolved.user, "%u", localUserName(), "%%", "%")
+	for _, file := range settings["identityfile"] {
+		resolved.identityFiles = append(resolved.identityFiles, expandHome(expand.Replace(file)))
+	}
+	if jumps := settings.first("proxyjump"); jumps != "" && !strings.EqualFold(jumps, "none") {
+		resolved.proxyJump = strings.Split(jumps, ",")
+	}
+	return resolved, nil
+}
+
+// parseJumpHost splits a ProxyJump hop, [ssh://][user@]host[:port], into its user and host[:port]
+func parseJumpHost(jump string) (string, string) {
+	jump = strings.TrimPrefix(strings.TrimSpace(jump), "ssh://")
+	if at := strings.LastIndex(jump, "@"); at >= 0 {
+		return jump[:at], jump[at+1:]
+	}
+	return "", jump
+}
+
+// sshSettings maps lowercase ssh_config keywords to their values for one host
+type sshSettings map[string][]string
+
+// first returns the value that applies, the first one given for the keyword
+func (s sshSettings) first(keyword string) string {
+	if values := s[keyword]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// readSSHConfig collects the settings ~/.ssh/config and then /etc/ssh/ssh_config
+// give for host. Match blocks are not evaluated and are skipped.
+func readSSHConfig(host string) (sshSettings, error) {
+	settings := sshSettings{}
+	home := os.Getenv("HOME")
+	for _, file := range []struct{ path, dir string }{
+		{filepath.Join(home, ".ssh", "config"), filepath.Join(home, ".ssh")},
+		{"/etc/ssh/ssh_config", "/etc/ssh"},
+	} {
+		if err := readSSHConfigFile(file.path, file.dir, strings.ToLower(host), settings, 0); err != nil {
+			return nil, err
+		}
+	}
+	return settings, nil
+}
+
+// readSSHConfigFile adds the settings of one config file, and of the files it
+// includes, that apply to host. Relative includes are looked up in dir.
+func readSSHConfigFile(file, dir, host string, settings sshSettings, depth int) error {
+	if depth > 16 {
+		return fmt.Errorf("too many nested Include directives in %s", file)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read SSH config: %w", err)
+	}
+	defer f.Close()
+
+	matching := true
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		keyword, args := splitSSHConfigLine(scanner.Text())
+		switch {
+		case keyword == "":
+		case keyword == "host":
+			matching = matchSSHHost(host, args)
+		case keyword == "match":
+			matching = false
+		case !matching:
+		case keyword == "include":
+			for _, pattern := range args {
+				pattern = expandHome(pattern)
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(dir, pattern)
+				}
+				files, err := filepath.Glob(pattern)
+				if err != nil {
+					return fmt.Errorf("%s:%d: invalid Include %q: %w", file, line, pattern, err)
+				}
+				for _, included := range files {
+					if err := readSSHConfigFile(included, dir, host, settings, depth+1); err != nil {
+						return err
+					}
+				}
+			}
+		case len(args) == 0:
+		case keyword == "identityfile":
+			settings[keyword] = append(settings[keyword], args[0])
+		case len(settings[keyword]) == 0:
+			settings[keyword] = []string{strings.Join(args, " ")}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read SSH config %s: %w", file, err)
+	}
+	return nil
+}
+
+// splitSSHConfigLine splits a "Keyword value" or "Keyword=value" line into the
+// lowercase keyword and its arguments, honouring double quotes and # comments
+func splitSSHConfigLine(line string) (string, []string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil
+	}
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return strings.ToLower(line), nil
+	}
+	keyword := strings.ToLower(line[:end])
+	rest := strings.TrimLeft(line[end:], " \t")
+	rest = strings.TrimLeft(strings.TrimPrefix(rest, "="), " \t")
+
+	var args []string
+	var arg strings.Builder
+	quoted, started := false, false
+	for _, r := range rest {
+		switch {
+		case r == '"':
+			quoted, started = !quoted, true
+		case !quoted && (r == ' ' || r == '\t'):
+			if started {
+				args = append(args, arg.String())
+				arg.Reset()
+				started = false
+			}
+		case !quoted && r == '#' && !started:
+			return keyword, args
+		default:
+			arg.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		args = append(args, arg.String())
+	}
+	return keyword, args
+}
+
+// matchSSHHost reports whether host matches a Host line: one of its patterns
+// matches and none of its negated !patterns does
+func matchSSHHost(host string, patterns []string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		ok, _ := path.Match(strings.ToLower(strings.TrimPrefix(pattern, "!")), host)
+		if ok && negated {
+			return false
+		}
+		matched = matched || ok
+	}
+	return matched
+}
+
+// expandHome replaces a leading ~/ with the home directory
+func expandHome(file string) string {
+	if file == "~" || strings.HasPrefix(file, "~/") {
+		return filepath.Join(os.Getenv("HOME"), file[1:])
+	}
+	return file
+}
+
+// localUserName returns the name of the user running omti, the default SSH user
+func localUserName() string {
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return ""
+}
+
+// sshTarget describes an SSH destination as [user@]host for log output
+func sshTarget(remoteUser, remoteHost string) string {
+	if remoteUser == "" {
+		return remoteHost
+	}
+	return remoteUser + "@" + remoteHost
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitSSHConfigLine(t *testing.T) {
+	tests := []struct {
+		line        string
+		wantKeyword string
+		wantArgs    []string
+	}{
+		{line: "Host db bastion", wantKeyword: "host", wantArgs: []string{"db", "bastion"}},
+		{line: "  HostName db.internal", wantKeyword: "hostname", wantArgs: []string{"db.internal"}},
+		{line: "Port=2222", wantKeyword: "port", wantArgs: []string{"2222"}},
+		{line: "Port = 2222", wantKeyword: "port", wantArgs: []string{"2222"}},
+		{line: "User\tdeploy", wantKeyword: "user", wantArgs: []string{"deploy"}},
+		{line: `IdentityFile "~/.ssh/my key"`, wantKeyword: "identityfile", wantArgs: []string{"~/.ssh/my key"}},
+		{line: `IdentityFile ""`, wantKeyword: "identityfile", wantArgs: []string{""}},
+		{line: "User deploy # the deploy user", wantKeyword: "user", wantArgs: []string{"deploy"}},
+		{line: "LocalCommand echo a#b", wantKeyword: "localcommand", wantArgs: []string{"echo", "a#b"}},
+		{line: "ForwardAgent", wantKeyword: "forwardagent"},
+		{line: "# Host db"},
+		{line: "   "},
+	}
+	for _, tt := range tests {
+		keyword, args := splitSSHConfigLine(tt.line)
+		if keyword != tt.wantKeyword || !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("splitSSHConfigLine(%q) = %q, %q, want %q, %q", tt.line, keyword, args, tt.wantKeyword, tt.wantArgs)
+		}
+	}
+}
+
+func TestMatchSSHHost(t *testing.T) {
+	tests := []struct {
+		host     string
+		patterns []string
+		want     bool
+	}{
+		{host: "db", patterns: []string{"db"}, want: true},
+		{host: "db", patterns: []string{"DB"}, want: true},
+		{host: "db.example.com", patterns: []string{"*.example.com"}, want: true},
+		{host: "db1", patterns: []string{"web", "db?"}, want: true},
+		{host: "db10", patterns: []string{"db?"}},
+		{host: "bastion.example.com", patterns: []string{"*.example.com", "!bastion.*"}},
+		{host: "bastion.example.com", patterns: []string{"!bastion.*", "*.example.com"}},
+		{host: "db.example.com", patterns: []string{"!bastion.*"}},
+		{host: "db.example.com", patterns: []string{"*", "!bastion.*"}, want: true},
+		{host: "db.example.org", patterns: []string{"*.example.com"}},
+	}
+	for _, tt := range tests {
+		if got := matchSSHHost(tt.host, tt.patterns); got != tt.want {
+			t.Errorf("matchSSHHost(%q, %q) = %v, want %v", tt.host, tt.patterns, got, tt.want)
+		}
+	}
+}
+
+func TestParseJumpHost(t *testing.T) {
+	tests := []struct {
+		jump, wantUser, wantHost string
+	}{
+		{jump: "bastion", wantHost: "bastion"},
+		{jump: "jump@bastion:2222", wantUser: "jump", wantHost: "bastion:2222"},
+		{jump: " ssh://jump@bastion ", wantUser: "jump", wantHost: "bastion"},
+	}
+	for _, tt := range tests {
+		if user, host := parseJumpHost(tt.jump); user != tt.wantUser || host != tt.wantHost {
+			t.Errorf("parseJumpHost(%q) = %q, %q, want %q, %q", tt.jump, user, host, tt.wantUser, tt.wantHost)
+		}
+	}
+}
+
+// writeSSHConfig creates a home directory with the given files under ~/.ssh
+// and makes it the home directory for the rest of the test
+func writeSSHConfig(t *testing.T, files map[string]string) string {
+	home := t.TempDir()
+	for name, content := range files {
+		file := filepath.Join(home, ".ssh", name)
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("HOME", home)
+	t.Setenv("USER", "local")
+	return home
+}
+
+func TestResolveSSHHost(t *testing.T) {
+	home := writeSSHConfig(t, map[string]string{
+		"config": `# Settings of included files come first
+Include conf.d/*.conf
+
+Host *.internal !bastion.internal
+    User deploy
+    Port 2200
+
+Host db
+    HostName %h.example.com
+    IdentityFile ~/.ssh/id_db
+    IdentityFile %d/.ssh/id_%r_%h
+    ProxyJump jump@bastion:2222,ssh://other
+
+Host direct
+    ProxyJump none
+
+Match host db
+    User ignored
+
+Host *
+    Port 2299
+    IdentityFile ~/.ssh/id_default
+`,
+		"conf.d/10-app.conf": `Host app
+    HostName 10.0.0.5
+    Port 2201
+`,
+		"conf.d/notes.txt": `Host *
+    User not-included
+`,
+	})
+	ssh := filepath.Join(home, ".ssh")
+
+	tests := []struct {
+		name string
+		user string
+		host string
+		want sshHost
+	}{
+		{
+			name: "host block with tokens and jumps",
+			host: "db",
+			want: sshHost{user: "local", hostname: "db.example.com", port: "2299",
+				identityFiles: []string{filepath.Join(ssh, "id_db"), filepath.Join(ssh, "id_local_db.example.com"), filepath.Join(ssh, "id_default")},
+				proxyJump:     []string{"jump@bastion:2222", "ssh://other"}},
+		},
+		{
+			name: "wildcard block",
+			host: "web.internal",
+			want: sshHost{user: "deploy", hostname: "web.internal", port: "2200", identityFiles: []string{filepath.Join(ssh, "id_default")}},
+		},
+		{
+			name: "negated pattern",
+			host: "bastion.internal",
+			want: sshHost{user: "local", hostname: "bastion.internal", port: "2299", identityFiles: []string{filepath.Join(ssh, "id_default")}},
+		},
+		{
+			name: "explicit user and port win",
+			user: "root",
+			host: "web.internal:2022",
+			want: sshHost{user: "root", hostname: "web.internal", port: "2022", identityFiles: []string{filepath.Join(ssh, "id_default")}},
+		},
+		{
+			name: "included file",
+			host: "app",
+			want: sshHost{user: "local", hostname: "10.0.0.5", port: "2201", identityFiles: []string{filepath.Join(ssh, "id_default")}},
+		},
+		{
+			name: "ProxyJump none",
+			host: "direct",
+			want: sshHost{user: "local", hostname: "direct", port: "2299", identityFiles: []string{filepath.Join(ssh, "id_default")}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveSSHHost(tt.user, tt.host)
+			if err != nil {
+				t.Fatalf("resolveSSHHost failed: %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("resolveSSHHost(%q, %q) = %+v, want %+v", tt.user, tt.host, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveSSHHostDefaults(t *testing.T) {
+	writeSSHConfig(t, nil)
+	got, err := resolveSSHHost("", "db.example.com")
+	if err != nil {
+		t.Fatalf("resolveSSHHost failed: %v", err)
+	}
+	want := sshHost{user: "local", hostname: "db.example.com", port: "22"}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("resolveSSHHost = %+v, want %+v", *got, want)
+	}
+}
+
+func TestResolveSSHHostIncludeLoop(t *testing.T) {
+	writeSSHConfig(t, map[string]string{"config": "Include config\n"})
+	_, err := resolveSSHHost("", "db")
+	if err == nil || !strings.Contains(err.Error(), "too many nested Include directives") {
+		t.Errorf("resolveSSHHost error = %v, want too many nested Include directives", err)
+	}
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshCommand streams the output of a command running on a remote host
+type sshCommand struct {
+	ctx     context.Context
+	name    string
+	target  string
+	client  *ssh.Client
+	session *ssh.Session
+	stdout  io.Reader
+	stderr  bytes.Buffer
+	done    chan struct{}
+}
+
+// startSSHCommand runs a shell command on the remote host, feeding it stdin when
+// it is not nil, and returns its output. name describes the command in errors.
+// When ctx is cancelled, the command gets SIGTERM and the connection is closed.
+func startSSHCommand(ctx context.Context, remoteUser, remoteHost, name, command string, stdin io.Reader) (*sshCommand, error) {
+	client, err := dialSSH(ctx, remoteUser, remoteHost)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to open SSH session on %s: %w", remoteHost, err)
+	}
+
+	c := &sshCommand{
+		ctx:     ctx,
+		name:    name,
+		target:  sshTarget(remoteUser, remoteHost),
+		client:  client,
+		session: session,
+		done:    make(chan struct{}),
+	}
+	session.Stdin = stdin
+	session.Stderr = &c.stderr
+	c.stdout, err = session.StdoutPipe()
+	if err != nil {
+		c.closeConnection()
+		return nil, fmt.Errorf("failed to capture %s output: %w", name, err)
+	}
+	if err := session.Start(command); err != nil {
+		c.closeConnection()
+		return nil, fmt.Errorf("failed to start %s on %s: %w", name, c.target, err)
+	}
+	go c.stopOnCancel()
+	return c, nil
+}
+
+// runSSHCommand runs a shell command on the remote host like startSSHCommand and waits for it to finish
+func runSSHCommand(ctx context.Context, remoteUser, remoteHost, name, command string, stdin io.Reader) error {
+	c, err := startSSHCommand(ctx, remoteUser, remoteHost, name, command, stdin)
+	if err != nil {
+		return err
+	}
+	return c.Close()
+}
+
+func (c *sshCommand) Read(p []byte) (int, error) {
+	return c.stdout.Read(p)
+}
+
+// Close waits for the remote command to exit and reports its failure
+func (c *sshCommand) Close() error {
+	defer c.closeConnection()
+	defer close(c.done)
+
+	// Drain the rest of the output so the command is not left blocked on a full channel
+	io.Copy(io.Discard, c.stdout)
+	err := c.session.Wait()
+	if cause := interruption(c.ctx); cause != nil {
+		return fmt.Errorf("%s on %s stopped: %w", c.name, c.target, cause)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to execute %s on %s: %w\nError: %s", c.name, c.target, err, c.stderr.String())
+	}
+	return nil
+}
+
+// stopOnCancel stops the remote command when ctx is cancelled before it finishes.
+// Closing the connection also ends the command if the server ignores the signal.
+func (c *sshCommand) stopOnCancel() {
+	select {
+	case <-c.ctx.Done():
+		c.session.Signal(ssh.SIGTERM)
+		c.closeConnection()
+	case <-c.done:
+	}
+}
+
+func (c *sshCommand) closeConnection() {
+	c.session.Close()
+	c.client.Close()
+}
+
+// shellQuote quotes a string for use as a single word in a POSIX shell command
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -1,9 +1,9 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -19,11 +19,12 @@ var tagCmd = &cobra.Command{
 		branchName := args[1]
 		folderPath := args[2]
 
+		ctx := cmd.Context()
 		logger := createCustomLogger()
 		logger.Info("🚀 Starting the tagging process")
 
 		// Run environment checks before proceeding
-		if err := checkEnvironment(); err != nil {
+		if err := checkEnvironment(ctx); err != nil {
 			logger.Fatalf("❌ Environment checks failed: %v", err)
 		}
 
@@ -33,7 +34,7 @@ var tagCmd = &cobra.Command{
 		}
 
 		// Check if the tag already exists
-		exists, err := tagExists(tagName)
+		exists, err := tagExists(ctx, tagName)
 		if err != nil {
 			logger.Fatalf("❌ Failed to check if tag exists: %v", err)
 		}
@@ -43,7 +44,7 @@ var tagCmd = &cobra.Command{
 		}
 
 		// Create the new tag
-		if err := createTag(tagName, branchName); err != nil {
+		if err := createTag(ctx, tagName, branchName); err != nil {
 			logger.Fatalf("❌ Failed to create tag '%s': %v", tagName, err)
 		}
 		logger.Infof("✅ Tag '%s' created successfully", tagName)
@@ -55,8 +56,8 @@ func init() {
 }
 
 // tagExists checks if a tag already exists in the repository
-func tagExists(tagName string) (bool, error) {
-	cmd := exec.Command("git", "tag", "--list", tagName)
+func tagExists(ctx context.Context, tagName string) (bool, error) {
+	cmd := commandContext(ctx, "git", "tag", "--list", tagName)
 	output, err := cmd.Output()
 	if err != nil {
 		return false, fmt.Errorf("failed to list tags: %w", err)
@@ -66,19 +67,19 @@ func tagExists(tagName string) (bool, error) {
 }
 
 // createTag creates a new tag on the latest commit of the specified branch
-func createTag(tagName, branchName string) error {
+func createTag(ctx context.Context, tagName, branchName string) error {
 	// Fetch the latest updates from the branch
-	if err := runCommand("git", "fetch", "origin", branchName); err != nil {
+	if err := runCommand(ctx, "git", "fetch", "origin", branchName); err != nil {
 		return fmt.Errorf("failed to fetch latest changes from branch %s: %w", branchName, err)
 	}
 
 	// Create the tag pointing to the latest commit of the branch
-	if err := runCommand("git", "tag", tagName, fmt.Sprintf("origin/%s", branchName)); err != nil {
+	if err := runCommand(ctx, "git", "tag", tagName, fmt.Sprintf("origin/%s", branchName)); err != nil {
 		return fmt.Errorf("failed to create tag: %w", err)
 	}
 
 	// Push the tag to the remote repository
-	if err := runCommand("git", "push", "origin", tagName); err != nil {
+	if err := runCommand(ctx, "git", "push", "origin", tagName); err != nil {
 		return fmt.Errorf("failed to push tag to remote: %w", err)
 	}
 	return nil
@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshTunnel forwards connections accepted on a local port to a target address through an SSH connection
+type sshTunnel struct {
+	client    *ssh.Client
+	listener  net.Listener
+	target    string
+	conns     sync.WaitGroup
+	closeOnce sync.Once
+	closeErr  error
+	stopWatch chan struct{}
+}
+
+// startSSHTunnel forwards the local localPort to dbHost:remoteDBPort through remoteUser@remoteHost.
+// An empty or zero localPort lets the OS pick a free ephemeral port, which
+// keeps concurrent tunnels from colliding; use localPort() to find it.
+// The tunnel is also closed when ctx is cancelled, so a running client
+// fails fast instead of hanging on a half-open connection.
+func startSSHTunnel(ctx context.Context, localPort, dbHost, remoteUser, remoteHost, remoteDBPort string) (*sshTunnel, error) {
+	client, err := dialSSH(ctx, remoteUser, remoteHost)
+	if err != nil {
+		return nil, err
+	}
+
+	if localPort == "" {
+		localPort = "0"
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", localPort))
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to listen on local port %s: %w", localPort, err)
+	}
+
+	tunnel := &sshTunnel{
+		client:    client,
+		listener:  listener,
+		target:    net.JoinHostPort(dbHost, remoteDBPort),
+		stopWatch: make(chan struct{}),
+	}
+	go tunnel.acceptLoop()
+	go tunnel.closeOnCancel(ctx)
+
+	fmt.Printf("✅ SSH tunnel listening on %s, forwarding to %s via %s\n", listener.Addr(), tunnel.target, remoteHost)
+	return tunnel, nil
+}
+
+// localPort returns the local port the tunnel is listening on
+func (t *sshTunnel) localPort() string {
+	return strconv.Itoa(t.listener.Addr().(*net.TCPAddr).Port)
+}
+
+// Close stops accepting connections, waits for forwarded connections to drain and closes the SSH connection
+func (t *sshTunnel) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.stopWatch)
+		t.listener.Close()
+		t.closeErr = t.client.Close()
+		t.conns.Wait()
+		fmt.Println("✅ SSH tunnel closed")
+	})
+	return t.closeErr
+}
+
+// acceptLoop forwards every accepted local connection until the listener is closed
+func (t *sshTunnel) acceptLoop() {
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		t.conns.Add(1)
+		go t.forward(local)
+	}
+}
+
+// forward copies data in both directions between a local connection and the remote target
+func (t *sshTunnel) forward(local net.Conn) {
+	defer t.conns.Done()
+	defer local.Close()
+
+	remote, err := t.client.Dial("tcp", t.target)
+	if err != nil {
+		fmt.Printf("❌ SSH tunnel failed to reach %s: %v\n", t.target, err)
+		return
+	}
+	defer remote.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// closeOnCancel closes the tunnel if ctx is cancelled while it is open
+func (t *sshTunnel) closeOnCancel(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		fmt.Println("❌ Interrupted, closing SSH tunnel")
+		go t.Close()
+	case <-t.stopWatch:
+	}
+}
+
+// dialSSH opens an authenticated SSH connection to remoteUser@remoteHost, where
+// remoteHost may carry the SSH port as host:port and the user may be empty. The
+// host's settings in ~/.ssh/config apply, including ProxyJump hops. It gives up
+// when ctx is cancelled during the handshake.
+func dialSSH(ctx context.Context, remoteUser, remoteHost string) (*ssh.Client, error) {
+	target, err := resolveSSHHost(remoteUser, remoteHost)
+	if err != nil {
+		return nil, err
+	}
+
+	var via *ssh.Client
+	for _, jump := range target.proxyJump {
+		jumpUser, jumpHost := parseJumpHost(jump)
+		hop, err := resolveSSHHost(jumpUser, jumpHost)
+		if err == nil {
+			var client *ssh.Client
+			client, err = dialSSHHost(ctx, via, hop)
+			if err == nil {
+				closeWith(client, via)
+				via = client
+				continue
+			}
+		}
+		if via != nil {
+			via.Close()
+		}
+		return nil, fmt.Errorf("failed to connect to %s through jump host %s: %w", sshTarget(remoteUser, remoteHost), jump, err)
+	}
+
+	client, err := dialSSHHost(ctx, via, target)
+	if err != nil {
+		if via != nil {
+			via.Close()
+		}
+		if cause := interruption(ctx); cause != nil {
+			return nil, fmt.Errorf("connecting to %s stopped: %w", sshTarget(remoteUser, remoteHost), cause)
+		}
+		return nil, fmt.Errorf("failed to connect to %s: %w", sshTarget(remoteUser, remoteHost), err)
+	}
+	closeWith(client, via)
+	return client, nil
+}
+
+// dialSSHHost connects and authenticates to host, directly or through the
+// connection via when it is not nil
+func dialSSHHost(ctx context.Context, via *ssh.Client, host *sshHost) (*ssh.Client, error) {
+	address := host.address()
+	config, closeAgent, err := sshClientConfig(host.user, address, host.identityFiles)
+	if err != nil {
+		return nil, err
+	}
+	// Agent keys sign during the handshake only
+	defer closeAgent()
+
+	var tcp net.Conn
+	if via != nil {
+		tcp, err = via.DialContext(ctx, "tcp", address)
+	} else {
+		dialer := net.Dialer{Timeout: config.Timeout}
+		tcp, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { tcp.Close() })
+	sshConn, chans, reqs, err := ssh.NewClientConn(tcp, address, config)
+	if !stop() && err == nil {
+		sshConn.Close()
+		err = context.Cause(ctx)
+	}
+	if err != nil {
+		tcp.Close()
+		return nil, err
+	}
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// closeWith closes the jump host connection via once client is closed
+func closeWith(client, via *ssh.Client) {
+	if via == nil {
+		return
+	}
+	go func() {
+		client.Wait()
+		via.Close()
+	}()
+}
+
+// sshClientConfig authenticates with the running ssh-agent and identityFiles, or the
+// default private keys in ~/.ssh when there are none, verifying the server at
+// address against ~/.ssh/known_hosts. The returned function closes the
+// connection to the agent once the handshake is done.
+func sshClientConfig(user, address string, identityFiles []string) (*ssh.ClientConfig, func(), error) {
+	sshDir := filepath.Join(os.Getenv("HOME"), ".ssh")
+
+	hostKeyCallback, err := knownhosts.New(filepath.Join(sshDir, "known_hosts"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load known_hosts (connect once with ssh to trust the host): %w", err)
+	}
+
+	var signers []ssh.Signer
+	closeAgent := func() {}
+	if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			closeAgent = func() { conn.Close() }
+			if agentSigners, err := agent.NewClient(conn).Signers(); err == nil {
+				signers = append(signers, agentSigners...)
+			}
+		}
+	}
+
+	keyFiles, keySource := identityFiles, strings.Join(identityFiles, ", ")
+	if len(keyFiles) == 0 {
+		for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+			keyFiles = append(keyFiles, filepath.Join(sshDir, name))
+		}
+		keySource = sshDir
+	}
+	for _, file := range keyFiles {
+		key, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			var passErr *ssh.PassphraseMissingError
+			if !errors.As(err, &passErr) {
+				fmt.Printf("❌ Skipping SSH key %s: %v\n", filepath.Base(file), err)
+			}
+			continue
+		}
+		signers = append(signers, signer)
+	}
+
+	if len(signers) == 0 {
+		closeAgent()
+		return nil, nil, fmt.Errorf("no usable SSH keys found in ssh-agent or %s", keySource)
+	}
+
+	return &ssh.ClientConfig{
+		User:              user,
+		Auth:              []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: knownHostKeyAlgorithms(hostKeyCallback, address),
+		Timeout:           15 * time.Second,
+	}, closeAgent, nil
+}
+
+// knownHostKeyAlgorithms returns the host key algorithms of the keys known_hosts
+// holds for address, in file order, or nil for an unknown host. Left to the
+// defaults, the server may offer a key type that is not listed for it, e.g.
+// ECDSA for a host trusted by its ED25519 key, which fails as a key mismatch.
+func knownHostKeyAlgorithms(hostKeyCallback ssh.HostKeyCallback, address string) []string {
+	// A key no host has makes the callback list the keys it knows for address
+	probe, err := ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	if err != nil {
+		return nil
+	}
+	var keyErr *knownhosts.KeyError
+	if err := hostKeyCallback(address, &net.TCPAddr{}, probe); !errors.As(err, &keyErr) {
+		return nil
+	}
+
+	known := keyErr.Want
+	sort.Slice(known, func(i, j int) bool { return known[i].Line < known[j].Line })
+	var algorithms []string
+	for _, key := range known {
+		if key.Key.Type() == ssh.KeyAlgoRSA {
+			// RSA keys sign with SHA-2 on current servers
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+			continue
+		}
+		algorithms = append(algorithms, key.Key.Type())
+	}
+	return algorithms
+}
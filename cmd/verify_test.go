@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+)
+
+func TestDumpEngine(t *testing.T) {
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	zw.Write(mongoArchive("orders", "items"))
+	zw.Close()
+
+	tar := make([]byte, 512)
+	copy(tar[257:], "ustar")
+
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{name: "pg_dump custom archive", head: []byte("PGDMP\x01\x0f\x00\x04\x08\x01"), want: "postgres"},
+		{name: "pg_dump tar archive", head: tar, want: "postgres"},
+		{name: "pg_dump plain dump", head: []byte("--\n-- PostgreSQL database dump\n--\n\n-- Dumped from database version 16.2\n"), want: "postgres"},
+		{name: "mysqldump", head: []byte("-- MySQL dump 10.13  Distrib 8.0.36, for Linux (x86_64)\n--\n"), want: "mysql"},
+		{name: "mariadb-dump", head: []byte("/*M!999999\\- enable the sandbox mode */ \n-- MariaDB dump 10.19-11.4.2-MariaDB, for Linux (x86_64)\n"), want: "mysql"},
+		{name: "sqlite3 .dump", head: append(append([]byte{}, sqliteDumpHeader...), "CREATE TABLE t(x);\n"...), want: "sqlite"},
+		{name: "mongodump archive", head: mongoArchive("orders", "items"), want: "mongodb"},
+		{name: "gzipped mongodump archive", head: gzipped.Bytes(), want: "mongodb"},
+		{name: "MySQL dump not at the start", head: []byte("SELECT 1;\n-- MySQL dump 10.13\n")},
+		{name: "gzipped text", head: gzipText("-- MySQL dump 10.13\n")},
+		{name: "empty", head: nil},
+		{name: "unknown SQL", head: []byte("CREATE TABLE t(x);\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if engine := dumpEngine(tt.head); engine != nil {
+				got = engine.name()
+			}
+			if got != tt.want {
+				t.Errorf("dumpEngine = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// gzipText returns s compressed with gzip
+func gzipText(s string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(s))
+	zw.Close()
+	return buf.Bytes()
+}